@@ -2,15 +2,29 @@ package dockerUtils
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
 
 
 func RunCommand(log *zerolog.Logger, name string, args ...string) error {
+	return RunCommandWithEnv(log, nil, name, args...)
+}
+
+// RunCommandWithEnv מריץ פקודה כמו RunCommand עם משתני סביבה נוספים (KEY=VALUE).
+// ללוג נכתבים רק שמות המשתנים - הערכים יכולים להיות סודיים
+func RunCommandWithEnv(log *zerolog.Logger, env []string, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
-	log.Debug().Strs("args", args).Msgf("⚙️ Executing command: %s", name)
+	event := log.Debug().Strs("args", args)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+		event = event.Strs("env", envNames(env))
+	}
+	event.Msgf("⚙️ Executing command: %s", name)
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -28,3 +42,35 @@ func RunCommand(log *zerolog.Logger, name string, args ...string) error {
 	return  err
 }
 
+// RunCommandOutput מריץ פקודה כמו RunCommand אבל מחזיר את ה-stdout בנפרד (למשל פלט JSON של gcloud).
+// במקרה של כישלון, ה-stderr מצורף לשגיאה כדי שהקורא יוכל לזהות את סוג הכישלון.
+func RunCommandOutput(log *zerolog.Logger, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	log.Debug().Strs("args", args).Msgf("⚙️ Executing command: %s", name)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("output", stderr.String()).
+			Str("command", name).
+			Msg("❌ Command execution failed")
+		return stdout.String(), fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// envNames - רק השמות מתוך KEY=VALUE
+func envNames(env []string) []string {
+	names := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		names = append(names, name)
+	}
+	return names
+}
@@ -26,6 +26,10 @@ type PushConfig struct {
 	ProjectID string
 	Region    string
 	RepoName  string
+	Repo      RepoSettings // הגדרות ליצירת ה-Repository ובדיקת סטייה
+
+	// בדיקת smoke מקומית בין ה-build ל-push (nil = ללא בדיקה)
+	SmokeTest *SmokeTestConfig
 }
 
 
@@ -163,6 +167,7 @@ func ensureGCPAuth(log *zerolog.Logger, region string) error {
 		return err
 	}
 
+	// ה-credential helper של gcloud מוציא token חדש בכל push - אין token שמור שיכול לפוג
 	log.Info().
 		Str("host", host).
 		Msg("✅ Docker authenticated with GCP Artifact Registry")
@@ -176,36 +181,38 @@ func ensureGCPRepo(log *zerolog.Logger, cfg PushConfig) error {
 		Str("region", cfg.Region).
 		Msg("🔍 Checking if GCP Artifact Registry repository exists...")
 
-	// 1. בדיקה אם ה-Repository קיים
-	checkArgs := []string{
-		"artifacts", "repositories", "describe",
-		cfg.RepoName,
-		"--project", cfg.ProjectID,
-		"--location", cfg.Region,
-		"--format", "value(name)", // מוציא פלט רק אם נמצא
-	}
-
-	if err := RunCommand(log, "gcloud", checkArgs...); err != nil {
-		log.Warn().Msg("⚠️ Repository not found. Attempting to create it...")
+	// 1. בדיקה אם ה-Repository קיים - מבדילים בין "לא קיים" ל"אין הרשאה"
+	existing, err := describeGCPRepo(log, cfg)
+	switch {
+	case err == nil:
+		log.Info().Msg("✅ Repository already exists")
 
-		// 2. יצירת ה-Repository אם הוא לא קיים
-		createArgs := []string{
-			"artifacts", "repositories", "create",
-			cfg.RepoName,
-			"--repository-format=docker",
-			"--project", cfg.ProjectID,
-			"--location", cfg.Region,
-			"--description=Auto-created by build script",
+		// בדיקת סטייה בין ההגדרות הקיימות להגדרות הרצויות
+		drift, err := detectRepoDrift(cfg.Repo, existing)
+		if err != nil {
+			log.Warn().Err(err).Msg("⚠️ Failed to check repository settings drift")
+			return nil
 		}
+		logRepoDrift(log, cfg, drift)
+		return nil
 
-		if err := RunCommand(log, "gcloud", createArgs...); err != nil {
-			log.Error().Err(err).Msg("❌ Failed to create GCP Artifact Registry repository")
-			return err
-		}
-		log.Info().Msg("✅ Repository created successfully")
-	} else {
-		log.Info().Msg("✅ Repository already exists")
+	case errors.Is(err, ErrRepoPermissionDenied):
+		log.Error().Err(err).Msg("❌ No permission to access the Artifact Registry repository (not creating it)")
+		return err
+
+	case !errors.Is(err, ErrRepoNotFound):
+		log.Error().Err(err).Msg("❌ Failed to check GCP Artifact Registry repository")
+		return err
+	}
+
+	log.Warn().Msg("⚠️ Repository not found. Attempting to create it...")
+
+	// 2. יצירת ה-Repository עם ההגדרות הרצויות
+	if err := RunCommand(log, "gcloud", createRepoArgs(cfg)...); err != nil {
+		log.Error().Err(err).Msg("❌ Failed to create GCP Artifact Registry repository")
+		return err
 	}
+	log.Info().Msg("✅ Repository created successfully")
 
 	return nil
 }
@@ -213,6 +220,12 @@ func ensureGCPRepo(log *zerolog.Logger, cfg PushConfig) error {
 
 // FullBuildTagPush performs the build, tag, and push sequence.
 func FullBuildTagPush(log *zerolog.Logger, buildPath, localTag, remoteTag string) error {
+	return fullBuildTagPush(log, buildPath, localTag, remoteTag, nil)
+}
+
+// fullBuildTagPush performs build, optional smoke test, tag and push.
+// The image is pushed only if the smoke test (when configured) passes.
+func fullBuildTagPush(log *zerolog.Logger, buildPath, localTag, remoteTag string, smoke *SmokeTestConfig) error {
 	log.Info().Msg("🚀 Starting Full Docker Build, Tag, and Push process...")
 	
 	RunDockerCheck(log)
@@ -223,6 +236,14 @@ func FullBuildTagPush(log *zerolog.Logger, buildPath, localTag, remoteTag string
 		return err
 	}
 
+	// 1.5. Smoke test (optional)
+	if smoke != nil {
+		if err := RunSmokeTest(log, localTag, *smoke); err != nil {
+			log.Error().Err(err).Msg("❌ Smoke test failed - image will not be pushed")
+			return err
+		}
+	}
+
 	// 2. Tag (optional, only if remoteTag is different from localTag)
 	if localTag != remoteTag {
 		if err := DockerTag(log, localTag, remoteTag); err != nil {
@@ -277,7 +298,7 @@ func FullBuildTagPushWithRegistry(
 	}
 
 	// המשך התהליך הרגיל (Build, Tag, Push)
-	if err := FullBuildTagPush(log, buildPath, localTag, remoteTag); err != nil {
+	if err := fullBuildTagPush(log, buildPath, localTag, remoteTag, cfg.SmokeTest); err != nil {
 		log.Error().
 			Err(err).
 			Msg("❌ Full Docker process failed")
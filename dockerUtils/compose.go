@@ -0,0 +1,249 @@
+package dockerUtils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Per-service status values reported by BuildPushCompose.
+const (
+	ComposeStatusPushed = "pushed"
+	ComposeStatusBuilt  = "built" // built, but no push mapping for this service
+	ComposeStatusFailed = "failed"
+)
+
+// ComposeServiceResult reports what happened to a single compose service.
+type ComposeServiceResult struct {
+	Service    string
+	LocalImage string
+	RemoteTag  string
+	Status     string
+	Err        error
+}
+
+// ComposeTargets builds the per-service push mapping for BuildPushCompose from a spec like
+// "web=gcp,worker=docker:worker-img": each service is pushed to the registry named on the
+// right (a key of registries), as an image named after the service unless a name follows ':'.
+func ComposeTargets(spec string, registries map[RegistryType]PushConfig) (map[string]PushConfig, error) {
+	targets := map[string]PushConfig{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		service, target, ok := strings.Cut(entry, "=")
+		service = strings.TrimSpace(service)
+		if !ok || service == "" {
+			return nil, fmt.Errorf("invalid compose mapping %q (expected service=registry[:image])", entry)
+		}
+		if _, dup := targets[service]; dup {
+			return nil, fmt.Errorf("service %q is mapped more than once", service)
+		}
+
+		registry, image, _ := strings.Cut(strings.TrimSpace(target), ":")
+		cfg, ok := registries[RegistryType(registry)]
+		if !ok {
+			return nil, fmt.Errorf("service %q: unknown registry %q", service, registry)
+		}
+		cfg.ImageName = service
+		if image != "" {
+			cfg.ImageName = image
+		}
+		targets[service] = cfg
+	}
+	return targets, nil
+}
+
+// החלק הרלוונטי מהפלט של docker compose config --format json
+type composeProject struct {
+	Name     string `json:"name"`
+	Services map[string]struct {
+		Image string           `json:"image"`
+		Build *json.RawMessage `json:"build"`
+	} `json:"services"`
+}
+
+// composeArgs - "docker compose" עם הקובץ שנבחר. קובץ ריק = compose מוצא בעצמו
+// את הקבצים הסטנדרטיים (compose.yaml, docker-compose.yml וכו')
+func composeArgs(composeFile string, args ...string) []string {
+	base := []string{"compose"}
+	if composeFile != "" {
+		base = append(base, "-f", composeFile)
+	}
+	return append(base, args...)
+}
+
+// loadComposeProject קורא ומנרמל את קובץ ה-compose דרך docker compose עצמו
+// (כך נתמכים extends, משתני סביבה ו-profiles בדיוק כמו ב-compose)
+func loadComposeProject(log *zerolog.Logger, composeFile string) (*composeProject, error) {
+	out, err := RunCommandOutput(log, "docker", composeArgs(composeFile, "config", "--format", "json")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var project composeProject
+	if err := json.Unmarshal([]byte(out), &project); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+	return &project, nil
+}
+
+// checkComposeTargets - כל שירות במיפוי חייב להיות בקובץ ועם build, אחרת שגיאת כתיב
+// במיפוי הייתה מדלגת על ה-push בשקט
+func checkComposeTargets(project *composeProject, targets map[string]PushConfig) error {
+	var unknown, notBuilt []string
+	for service := range targets {
+		svc, ok := project.Services[service]
+		switch {
+		case !ok:
+			unknown = append(unknown, service)
+		case svc.Build == nil:
+			notBuilt = append(notBuilt, service)
+		}
+	}
+	sort.Strings(unknown)
+	sort.Strings(notBuilt)
+
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, "unknown services: "+strings.Join(unknown, ", "))
+	}
+	if len(notBuilt) > 0 {
+		problems = append(problems, "services without a build section: "+strings.Join(notBuilt, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("compose push mapping: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// BuildPushCompose builds every service in composeFile (empty = compose's default file names)
+// that has a `build:` section, tags it according to targets (service name -> PushConfig) and pushes it.
+// A target naming a service that is not in the file, or has no build section, is an error.
+// Services without a mapping are built but not pushed. The returned error is non-nil
+// if at least one service failed; the results always contain every buildable service.
+func BuildPushCompose(log *zerolog.Logger, composeFile string, targets map[string]PushConfig) ([]ComposeServiceResult, error) {
+	log.Info().Str("file", composeFile).Msg("🚀 Starting docker compose build/tag/push")
+
+	RunDockerCheck(log)
+
+	project, err := loadComposeProject(log, composeFile)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Failed to read compose file")
+		return nil, err
+	}
+	if err := checkComposeTargets(project, targets); err != nil {
+		log.Error().Err(err).Msg("❌ Invalid compose push mapping")
+		return nil, err
+	}
+
+	services := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	var results []ComposeServiceResult
+	failed := 0
+
+	for _, name := range services {
+		svc := project.Services[name]
+		if svc.Build == nil {
+			log.Debug().Str("service", name).Msg("Skipping service without build section")
+			continue
+		}
+
+		// שם ה-image ש-compose נותן אחרי build
+		localImage := svc.Image
+		if localImage == "" {
+			localImage = fmt.Sprintf("%s-%s", project.Name, name)
+		}
+
+		result := buildPushComposeService(log, composeFile, name, localImage, targets)
+		if result.Status == ComposeStatusFailed {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	// סיכום לכל שירות
+	for _, r := range results {
+		event := log.Info()
+		if r.Status == ComposeStatusFailed {
+			event = log.Error().Err(r.Err)
+		}
+		event.
+			Str("service", r.Service).
+			Str("image", r.LocalImage).
+			Str("remoteTag", r.RemoteTag).
+			Str("status", r.Status).
+			Msg("📊 Compose service result")
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d compose services failed", failed, len(results))
+	}
+
+	log.Info().Int("services", len(results)).Msg("✨ docker compose build/tag/push completed successfully")
+	return results, nil
+}
+
+func buildPushComposeService(
+	log *zerolog.Logger,
+	composeFile string,
+	service string,
+	localImage string,
+	targets map[string]PushConfig,
+) ComposeServiceResult {
+	result := ComposeServiceResult{Service: service, LocalImage: localImage}
+
+	fail := func(err error) ComposeServiceResult {
+		result.Status = ComposeStatusFailed
+		result.Err = err
+		return result
+	}
+
+	log.Info().Str("service", service).Str("image", localImage).Msg("🔨 Building compose service...")
+	if err := RunCommand(log, "docker", composeArgs(composeFile, "build", service)...); err != nil {
+		return fail(err)
+	}
+
+	cfg, ok := targets[service]
+	if !ok {
+		log.Warn().Str("service", service).Msg("⚠️ No push mapping for service - built only")
+		result.Status = ComposeStatusBuilt
+		return result
+	}
+
+	remoteTag, err := buildRemoteTag(log, cfg)
+	if err != nil {
+		return fail(err)
+	}
+	result.RemoteTag = remoteTag
+
+	if err := prepareRegistry(log, cfg, true); err != nil {
+		return fail(err)
+	}
+
+	if cfg.SmokeTest != nil {
+		if err := RunSmokeTest(log, localImage, *cfg.SmokeTest); err != nil {
+			return fail(err)
+		}
+	}
+
+	if localImage != remoteTag {
+		if err := DockerTag(log, localImage, remoteTag); err != nil {
+			return fail(err)
+		}
+	}
+	if err := DockerPush(log, remoteTag); err != nil {
+		return fail(err)
+	}
+
+	result.Status = ComposeStatusPushed
+	return result
+}
@@ -0,0 +1,142 @@
+package dockerUtils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func testRegistries() map[RegistryType]PushConfig {
+	return map[RegistryType]PushConfig{
+		RegistryDocker: {
+			Registry:        RegistryDocker,
+			DockerNamespace: "myusername",
+			ImageName:       "wiki",
+			Tag:             "latest",
+		},
+		RegistryGCP: {
+			Registry:  RegistryGCP,
+			ImageName: "wiki",
+			Tag:       "v1",
+			ProjectID: "my-project",
+			Region:    "me-west1",
+			RepoName:  "wiki-registry",
+		},
+	}
+}
+
+func TestComposeTargetsRemoteTags(t *testing.T) {
+	log := zerolog.Nop()
+
+	targets, err := ComposeTargets(" web=gcp, worker=docker:worker-img ,api=gcp:backend,", testRegistries())
+	if err != nil {
+		t.Fatalf("ComposeTargets() error = %v", err)
+	}
+
+	want := map[string]string{
+		"web":    "me-west1-docker.pkg.dev/my-project/wiki-registry/web:v1",
+		"worker": "myusername/worker-img:latest",
+		"api":    "me-west1-docker.pkg.dev/my-project/wiki-registry/backend:v1",
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("ComposeTargets() returned %d services, want %d: %+v", len(targets), len(want), targets)
+	}
+	for service, tag := range want {
+		cfg, ok := targets[service]
+		if !ok {
+			t.Errorf("service %q has no mapping", service)
+			continue
+		}
+		got, err := buildRemoteTag(&log, cfg)
+		if err != nil {
+			t.Errorf("buildRemoteTag(%s) error = %v", service, err)
+			continue
+		}
+		if got != tag {
+			t.Errorf("remote tag of %s = %q, want %q", service, got, tag)
+		}
+	}
+}
+
+func TestComposeTargetsDoesNotShareConfigs(t *testing.T) {
+	registries := testRegistries()
+	targets, err := ComposeTargets("web=gcp,worker=gcp", registries)
+	if err != nil {
+		t.Fatalf("ComposeTargets() error = %v", err)
+	}
+	if targets["web"].ImageName != "web" || targets["worker"].ImageName != "worker" {
+		t.Errorf("image names = %q, %q, want web, worker", targets["web"].ImageName, targets["worker"].ImageName)
+	}
+	if registries[RegistryGCP].ImageName != "wiki" {
+		t.Errorf("ComposeTargets() changed the base registry config")
+	}
+}
+
+func TestComposeTargetsErrors(t *testing.T) {
+	tests := []string{
+		"web",                // בלי registry
+		"=gcp",               // בלי service
+		"web=quay",           // registry לא מוכר
+		"web=gcp,web=docker", // אותו service פעמיים
+	}
+	for _, spec := range tests {
+		if _, err := ComposeTargets(spec, testRegistries()); err == nil {
+			t.Errorf("ComposeTargets(%q) error = nil, want an error", spec)
+		}
+	}
+}
+
+func TestCheckComposeTargets(t *testing.T) {
+	var project composeProject
+	if err := json.Unmarshal([]byte(`{
+		"name": "wiki",
+		"services": {
+			"web": {"build": {"context": "."}},
+			"worker": {"build": {"context": "./worker"}},
+			"db": {"image": "postgres:16"}
+		}
+	}`), &project); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		targets map[string]PushConfig
+		wantErr []string // חלקים שחייבים להופיע בשגיאה
+	}{
+		{"all known", map[string]PushConfig{"web": {}, "worker": {}}, nil},
+		{"no mapping", nil, nil},
+		{"typo", map[string]PushConfig{"web": {}, "wroker": {}, "api": {}}, []string{"unknown services: api, wroker"}},
+		{"no build section", map[string]PushConfig{"db": {}}, []string{"without a build section: db"}},
+	}
+	for _, tt := range tests {
+		err := checkComposeTargets(&project, tt.targets)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: checkComposeTargets() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: checkComposeTargets() error = nil, want an error", tt.name)
+			continue
+		}
+		for _, part := range tt.wantErr {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%s: error %q does not contain %q", tt.name, err, part)
+			}
+		}
+	}
+}
+
+func TestComposeArgs(t *testing.T) {
+	if got := composeArgs("", "build", "web"); !reflect.DeepEqual(got, []string{"compose", "build", "web"}) {
+		t.Errorf("composeArgs(\"\") = %v", got)
+	}
+	if got := composeArgs("compose.prod.yaml", "build"); !reflect.DeepEqual(got, []string{"compose", "-f", "compose.prod.yaml", "build"}) {
+		t.Errorf("composeArgs(file) = %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package dockerUtils
+
+import (
+	"fmt"
+	"strings"
+
+	"DevOps/history"
+
+	"github.com/rs/zerolog"
+)
+
+// stripTag מסיר את ה-tag מ-reference מלא (registry/path/image:tag -> registry/path/image)
+func stripTag(ref string) string {
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref, ":"); colon > slash {
+		return ref[:colon]
+	}
+	return ref
+}
+
+// ResolveDigest returns the manifest digest (sha256:...) of a remote image reference.
+func ResolveDigest(log *zerolog.Logger, ref string) (string, error) {
+	out, err := RunCommandOutput(
+		log,
+		"docker", "buildx", "imagetools", "inspect", ref,
+		"--format", "{{.Manifest.Digest}}",
+	)
+	if err != nil {
+		return "", err
+	}
+
+	digest := strings.TrimSpace(out)
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("unexpected digest for %s: %q", ref, digest)
+	}
+	return digest, nil
+}
+
+// prepareRegistry מוודא התחברות (ואם צריך - קיום Repository) עבור registry של GCP
+func prepareRegistry(log *zerolog.Logger, cfg PushConfig, ensureRepo bool) error {
+	if cfg.Registry != RegistryGCP {
+		return nil
+	}
+	if err := ensureGCPAuth(log, cfg.Region); err != nil {
+		return err
+	}
+	if ensureRepo {
+		return ensureGCPRepo(log, cfg)
+	}
+	return nil
+}
+
+// PromoteImage copies an already-pushed image from src to dst by digest, without rebuilding.
+// The manifest and layers are copied registry-to-registry, and the destination digest is
+// verified to be identical to the source digest. The promotion is recorded in run history.
+func PromoteImage(log *zerolog.Logger, src, dst PushConfig) (digest string, err error) {
+	entry := history.NewEntry("docker-promote")
+	defer func() {
+		entry.Finish(err)
+		if recErr := history.Record(entry); recErr != nil {
+			log.Warn().Err(recErr).Msg("⚠️ Failed to record promotion in run history")
+		}
+	}()
+
+	srcTag, err := buildRemoteTag(log, src)
+	if err != nil {
+		return "", err
+	}
+	dstTag, err := buildRemoteTag(log, dst)
+	if err != nil {
+		return "", err
+	}
+	entry.Details["source"] = srcTag
+	entry.Details["destination"] = dstTag
+
+	log.Info().
+		Str("source", srcTag).
+		Str("destination", dstTag).
+		Msg("🚚 Promoting Docker image by digest")
+
+	// 1. התחברות ל-registries (ויצירת ה-Repository ביעד אם צריך)
+	if err = prepareRegistry(log, src, false); err != nil {
+		return "", err
+	}
+	if err = prepareRegistry(log, dst, true); err != nil {
+		return "", err
+	}
+
+	// 2. נעילת המקור ל-digest כדי שלא נקדם tag שזז בינתיים
+	digest, err = ResolveDigest(log, srcTag)
+	if err != nil {
+		log.Error().Err(err).Str("source", srcTag).Msg("❌ Failed to resolve source image digest")
+		return "", err
+	}
+	entry.Details["digest"] = digest
+	sourceRef := stripTag(srcTag) + "@" + digest
+
+	log.Info().Str("sourceRef", sourceRef).Msg("📌 Source image resolved")
+
+	// 3. העתקת ה-manifest וה-layers ישירות בין ה-registries (מקור יחיד = העתקה זהה)
+	if err = RunCommand(log, "docker", "buildx", "imagetools", "create", "--tag", dstTag, sourceRef); err != nil {
+		log.Error().Err(err).Msg("❌ Failed to copy image to destination registry")
+		return "", err
+	}
+
+	// 4. אימות שה-digest ביעד זהה למקור
+	dstDigest, err := ResolveDigest(log, dstTag)
+	if err != nil {
+		log.Error().Err(err).Str("destination", dstTag).Msg("❌ Failed to resolve destination image digest")
+		return "", err
+	}
+	if dstDigest != digest {
+		err = fmt.Errorf("digest mismatch after promotion: source %s, destination %s", digest, dstDigest)
+		log.Error().Err(err).Msg("❌ Promotion verification failed")
+		return "", err
+	}
+
+	log.Info().
+		Str("destination", dstTag).
+		Str("digest", digest).
+		Msg("✅ Image promoted successfully (digest verified)")
+	return digest, nil
+}
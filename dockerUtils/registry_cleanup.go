@@ -0,0 +1,382 @@
+package dockerUtils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// RetentionPolicy defines which images are kept in an Artifact Registry repository.
+// An image version is kept if ANY of the keep rules matches it.
+type RetentionPolicy struct {
+	KeepLast          int           // number of most recent tagged versions to keep per image
+	KeepTagPattern    string        // regexp - tags matching it are always kept (e.g. "^v[0-9]+")
+	UntaggedOlderThan time.Duration // untagged versions older than this are deleted (0 = keep all untagged)
+	DryRun            bool          // only report, don't delete
+}
+
+// ImageVersion is a single digest in the repository, as reported by gcloud.
+type ImageVersion struct {
+	Package    string // e.g. me-west1-docker.pkg.dev/project/repo/wiki
+	Version    string // sha256:...
+	Tags       []string
+	CreateTime time.Time // zero if gcloud reported a time that could not be parsed
+	MediaType  string
+	Children   []string // multi-arch index only: digests of the per-platform manifests
+}
+
+// media types של multi-arch index (OCI / Docker manifest list)
+var indexMediaTypes = map[string]bool{
+	"application/vnd.oci.image.index.v1+json":                   true,
+	"application/vnd.docker.distribution.manifest.list.v2+json": true,
+}
+
+// CleanupDecision describes what the retention policy decided for one image version.
+type CleanupDecision struct {
+	Image  ImageVersion
+	Delete bool
+	Reason string
+}
+
+// gcloud מחזיר את ה-tags לפעמים כמחרוזת מופרדת בפסיקים ולפעמים כמערך
+type gcloudTags []string
+
+func (t *gcloudTags) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = list
+		return nil
+	}
+
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		return err
+	}
+	*t = nil
+	for _, tag := range strings.Split(joined, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+type gcloudImage struct {
+	Package    string     `json:"package"`
+	Version    string     `json:"version"`
+	Tags       gcloudTags `json:"tags"`
+	CreateTime string     `json:"createTime"`
+	Metadata   struct {
+		MediaType string `json:"mediaType"`
+	} `json:"metadata"`
+}
+
+// repositoryPath מחזיר את הנתיב המלא של ה-Repository ב-Artifact Registry
+func repositoryPath(cfg PushConfig) (string, error) {
+	if cfg.ProjectID == "" || cfg.Region == "" || cfg.RepoName == "" {
+		return "", errors.New("missing GCP registry parameters")
+	}
+	return fmt.Sprintf("%s-docker.pkg.dev/%s/%s", cfg.Region, cfg.ProjectID, cfg.RepoName), nil
+}
+
+func parseGCloudTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized gcloud time %q", value)
+}
+
+// indexChildren מחזיר את ה-digests של ה-manifests (פלטפורמה לכל אחד) ש-index מצביע עליהם
+func indexChildren(log *zerolog.Logger, img ImageVersion) ([]string, error) {
+	out, err := RunCommandOutput(log, "docker", "manifest", "inspect", fmt.Sprintf("%s@%s", img.Package, img.Version))
+	if err != nil {
+		return nil, err
+	}
+	var index struct {
+		Manifests []struct {
+			Digest string `json:"digest"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal([]byte(out), &index); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest index: %w", err)
+	}
+	children := make([]string, 0, len(index.Manifests))
+	for _, m := range index.Manifests {
+		children = append(children, m.Digest)
+	}
+	return children, nil
+}
+
+// ListRepositoryImages lists every image version (digest) in the configured GCP repository.
+func ListRepositoryImages(log *zerolog.Logger, cfg PushConfig) ([]ImageVersion, error) {
+	repo, err := repositoryPath(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Cannot list images without full GCP registry config")
+		return nil, err
+	}
+
+	log.Info().Str("repo", repo).Msg("📋 Listing images in Artifact Registry repository...")
+
+	out, err := RunCommandOutput(
+		log,
+		"gcloud",
+		"artifacts", "docker", "images", "list", repo,
+		"--include-tags",
+		"--format=json",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []gcloudImage
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse gcloud images output: %w", err)
+	}
+
+	images := make([]ImageVersion, 0, len(raw))
+	for _, img := range raw {
+		created, err := parseGCloudTime(img.CreateTime)
+		if err != nil {
+			// בלי זמן יצירה אי אפשר לדעת את הגיל - ApplyRetentionPolicy שומר את הגרסה
+			log.Warn().Err(err).Str("image", img.Package).Str("digest", img.Version).Msg("⚠️ Unknown image creation time - version will be kept")
+		}
+		version := ImageVersion{
+			Package:    img.Package,
+			Version:    img.Version,
+			Tags:       img.Tags,
+			CreateTime: created,
+			MediaType:  img.Metadata.MediaType,
+		}
+		if indexMediaTypes[version.MediaType] {
+			if version.Children, err = indexChildren(log, version); err != nil {
+				// בלי רשימת הילדים אי אפשר לדעת אילו digests לא מתויגים שייכים ל-index
+				return nil, fmt.Errorf("failed to read multi-arch index %s@%s: %w", img.Package, img.Version, err)
+			}
+		}
+		images = append(images, version)
+	}
+
+	log.Info().Int("count", len(images)).Msg("✅ Images listed")
+	return images, nil
+}
+
+// ApplyRetentionPolicy decides, per image version, whether it should be kept or deleted.
+// Versions with an unknown creation time and the per-platform manifests of a kept
+// multi-arch index are always kept. It does not touch the registry.
+func ApplyRetentionPolicy(images []ImageVersion, policy RetentionPolicy, now time.Time) ([]CleanupDecision, error) {
+	var keepPattern *regexp.Regexp
+	if policy.KeepTagPattern != "" {
+		re, err := regexp.Compile(policy.KeepTagPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep tag pattern %q: %w", policy.KeepTagPattern, err)
+		}
+		keepPattern = re
+	}
+
+	// קיבוץ לפי image (package) ומיון מהחדש לישן
+	byPackage := map[string][]ImageVersion{}
+	var packages []string
+	for _, img := range images {
+		if _, ok := byPackage[img.Package]; !ok {
+			packages = append(packages, img.Package)
+		}
+		byPackage[img.Package] = append(byPackage[img.Package], img)
+	}
+	sort.Strings(packages)
+
+	var decisions []CleanupDecision
+	for _, pkg := range packages {
+		versions := byPackage[pkg]
+		sort.SliceStable(versions, func(i, j int) bool {
+			return versions[i].CreateTime.After(versions[j].CreateTime)
+		})
+
+		taggedSeen := 0
+		for _, img := range versions {
+			decision := CleanupDecision{Image: img}
+
+			switch {
+			case img.CreateTime.IsZero():
+				decision.Reason = "creation time unknown"
+
+			case len(img.Tags) == 0:
+				age := now.Sub(img.CreateTime)
+				if policy.UntaggedOlderThan > 0 && age > policy.UntaggedOlderThan {
+					decision.Delete = true
+					decision.Reason = fmt.Sprintf("untagged and older than %s", policy.UntaggedOlderThan)
+				} else {
+					decision.Reason = "untagged but within retention window"
+				}
+
+			case matchesAnyTag(keepPattern, img.Tags):
+				decision.Reason = "tag matches keep pattern"
+
+			default:
+				taggedSeen++
+				if taggedSeen <= policy.KeepLast {
+					decision.Reason = fmt.Sprintf("within last %d tagged versions", policy.KeepLast)
+				} else {
+					decision.Delete = true
+					decision.Reason = fmt.Sprintf("older than last %d tagged versions", policy.KeepLast)
+				}
+			}
+
+			decisions = append(decisions, decision)
+		}
+	}
+
+	// manifest של פלטפורמה אחת בתוך multi-arch index הוא digest לא מתויג - מחיקה שלו
+	// שוברת את ה-index. שומרים את הילדים של כל index שנשאר
+	keptChildren := map[string]string{} // package@digest -> digest של ה-index
+	for _, d := range decisions {
+		if d.Delete {
+			continue
+		}
+		for _, child := range d.Image.Children {
+			keptChildren[d.Image.Package+"@"+child] = d.Image.Version
+		}
+	}
+	for i, d := range decisions {
+		if index, ok := keptChildren[d.Image.Package+"@"+d.Image.Version]; ok && d.Delete {
+			decisions[i].Delete = false
+			decisions[i].Reason = "platform manifest of kept multi-arch index " + index
+		}
+	}
+
+	return decisions, nil
+}
+
+// deletionOrder מחזיר את הגרסאות למחיקה כשה-multi-arch indexes ראשונים: manifest של
+// פלטפורמה לא נמחק כל עוד index מצביע עליו
+func deletionOrder(decisions []CleanupDecision) []CleanupDecision {
+	var indexes, others []CleanupDecision
+	for _, d := range decisions {
+		switch {
+		case !d.Delete:
+		case len(d.Image.Children) > 0 || indexMediaTypes[d.Image.MediaType]:
+			indexes = append(indexes, d)
+		default:
+			others = append(others, d)
+		}
+	}
+	return append(indexes, others...)
+}
+
+func matchesAnyTag(re *regexp.Regexp, tags []string) bool {
+	if re == nil {
+		return false
+	}
+	for _, tag := range tags {
+		if re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteImageVersion מוחק digest בודד יחד עם כל ה-tags שמצביעים עליו
+func deleteImageVersion(log *zerolog.Logger, img ImageVersion) error {
+	ref := fmt.Sprintf("%s@%s", img.Package, img.Version)
+	return RunCommand(
+		log,
+		"gcloud",
+		"artifacts", "docker", "images", "delete", ref,
+		"--delete-tags",
+		"--quiet",
+	)
+}
+
+// CleanupRegistry applies the retention policy to the GCP repository described by cfg.
+// With policy.DryRun it only logs the report; otherwise it deletes every version marked for deletion.
+func CleanupRegistry(log *zerolog.Logger, cfg PushConfig, policy RetentionPolicy) ([]CleanupDecision, error) {
+	log.Info().
+		Str("repo", cfg.RepoName).
+		Int("keepLast", policy.KeepLast).
+		Str("keepPattern", policy.KeepTagPattern).
+		Dur("untaggedOlderThan", policy.UntaggedOlderThan).
+		Bool("dryRun", policy.DryRun).
+		Msg("🧹 Starting Artifact Registry cleanup")
+
+	if cfg.Registry != RegistryGCP {
+		return nil, errors.New("registry cleanup is only supported for GCP Artifact Registry")
+	}
+
+	if err := ensureGCPAuth(log, cfg.Region); err != nil {
+		return nil, err
+	}
+
+	images, err := ListRepositoryImages(log, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions, err := ApplyRetentionPolicy(images, policy, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Invalid retention policy")
+		return nil, err
+	}
+
+	// דוח - שורה לכל גרסה
+	toDelete := 0
+	for _, d := range decisions {
+		event := log.Info()
+		action := "KEEP"
+		if d.Delete {
+			event = log.Warn()
+			action = "DELETE"
+			toDelete++
+		}
+		event.
+			Str("image", d.Image.Package).
+			Str("digest", d.Image.Version).
+			Strs("tags", d.Image.Tags).
+			Time("created", d.Image.CreateTime).
+			Str("reason", d.Reason).
+			Msgf("📝 %s", action)
+	}
+
+	log.Info().
+		Int("total", len(decisions)).
+		Int("delete", toDelete).
+		Int("keep", len(decisions)-toDelete).
+		Msg("📊 Retention report")
+
+	if policy.DryRun {
+		log.Info().Msg("🔎 Dry-run mode - nothing was deleted")
+		return decisions, nil
+	}
+
+	var failed int
+	blocked := map[string]string{} // package@digest -> index שלא נמחק ועדיין מצביע עליו
+	for _, d := range deletionOrder(decisions) {
+		if index, ok := blocked[d.Image.Package+"@"+d.Image.Version]; ok {
+			log.Error().Str("digest", d.Image.Version).Str("index", index).Msg("❌ Skipping platform manifest - its multi-arch index was not deleted")
+			failed++
+			continue
+		}
+		if err := deleteImageVersion(log, d.Image); err != nil {
+			log.Error().Err(err).Str("digest", d.Image.Version).Msg("❌ Failed to delete image version")
+			failed++
+			for _, child := range d.Image.Children {
+				blocked[d.Image.Package+"@"+child] = d.Image.Version
+			}
+			continue
+		}
+		log.Info().Str("image", d.Image.Package).Str("digest", d.Image.Version).Msg("🗑️ Image version deleted")
+	}
+
+	if failed > 0 {
+		return decisions, fmt.Errorf("failed to delete %d image versions", failed)
+	}
+
+	log.Info().Int("deleted", toDelete).Msg("✨ Artifact Registry cleanup completed")
+	return decisions, nil
+}
@@ -0,0 +1,160 @@
+package dockerUtils
+
+import (
+	"testing"
+	"time"
+)
+
+const testPackage = "me-west1-docker.pkg.dev/my-project/wiki-registry/wiki"
+
+func testImage(version string, age time.Duration, now time.Time, tags ...string) ImageVersion {
+	return ImageVersion{
+		Package:    testPackage,
+		Version:    version,
+		Tags:       tags,
+		CreateTime: now.Add(-age),
+	}
+}
+
+// decisionsByDigest - ההחלטה לכל digest, כדי לבדוק בלי תלות בסדר
+func decisionsByDigest(t *testing.T, decisions []CleanupDecision) map[string]CleanupDecision {
+	t.Helper()
+	byDigest := make(map[string]CleanupDecision, len(decisions))
+	for _, d := range decisions {
+		byDigest[d.Image.Version] = d
+	}
+	return byDigest
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	images := []ImageVersion{
+		testImage("sha256:t1", 1*day, now, "latest", "build-5"),
+		testImage("sha256:t2", 2*day, now, "build-4"),
+		testImage("sha256:t3", 3*day, now, "build-3"),
+		testImage("sha256:v1", 30*day, now, "v1.0.0"),
+		testImage("sha256:u-new", 1*day, now),
+		testImage("sha256:u-old", 10*day, now),
+		{Package: testPackage, Version: "sha256:u-unknown"},
+		{Package: testPackage, Version: "sha256:t-unknown", Tags: []string{"build-0"}},
+	}
+	policy := RetentionPolicy{KeepLast: 2, KeepTagPattern: `^v[0-9]+`, UntaggedOlderThan: 7 * day}
+
+	decisions, err := ApplyRetentionPolicy(images, policy, now)
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy() error = %v", err)
+	}
+	if len(decisions) != len(images) {
+		t.Fatalf("ApplyRetentionPolicy() returned %d decisions, want %d", len(decisions), len(images))
+	}
+
+	want := map[string]bool{ // digest -> delete
+		"sha256:t1":        false, // בין 2 האחרונים
+		"sha256:t2":        false,
+		"sha256:t3":        true,  // מעבר ל-KeepLast
+		"sha256:v1":        false, // keep pattern, לא נספר ב-KeepLast
+		"sha256:u-new":     false, // בתוך חלון השמירה
+		"sha256:u-old":     true,
+		"sha256:u-unknown": false, // זמן יצירה לא ידוע - לא מוחקים
+		"sha256:t-unknown": false,
+	}
+	got := decisionsByDigest(t, decisions)
+	for digest, del := range want {
+		if got[digest].Delete != del {
+			t.Errorf("%s: Delete = %v (%s), want %v", digest, got[digest].Delete, got[digest].Reason, del)
+		}
+	}
+}
+
+func TestApplyRetentionPolicyKeepsMultiArchChildren(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	kept := testImage("sha256:index-new", 10*day, now, "latest")
+	kept.Children = []string{"sha256:amd64-new", "sha256:arm64-new"}
+	dropped := testImage("sha256:index-old", 20*day, now, "old")
+	dropped.Children = []string{"sha256:amd64-old"}
+
+	images := []ImageVersion{
+		kept,
+		dropped,
+		testImage("sha256:amd64-new", 10*day, now),
+		testImage("sha256:arm64-new", 10*day, now),
+		testImage("sha256:amd64-old", 20*day, now),
+		// אותו digest ב-package אחר הוא לא ילד של ה-index
+		{Package: testPackage + "-other", Version: "sha256:arm64-new", CreateTime: now.Add(-10 * day)},
+	}
+	policy := RetentionPolicy{KeepLast: 1, UntaggedOlderThan: 7 * day}
+
+	decisions, err := ApplyRetentionPolicy(images, policy, now)
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy() error = %v", err)
+	}
+
+	for _, d := range decisions {
+		want := true
+		if d.Image.Package == testPackage {
+			switch d.Image.Version {
+			case "sha256:index-new", "sha256:amd64-new", "sha256:arm64-new":
+				want = false
+			}
+		}
+		if d.Delete != want {
+			t.Errorf("%s@%s: Delete = %v (%s), want %v", d.Image.Package, d.Image.Version, d.Delete, d.Reason, want)
+		}
+	}
+}
+
+func TestApplyRetentionPolicyInvalidPattern(t *testing.T) {
+	if _, err := ApplyRetentionPolicy(nil, RetentionPolicy{KeepTagPattern: "("}, time.Now()); err == nil {
+		t.Error("ApplyRetentionPolicy() with an invalid pattern: error = nil, want an error")
+	}
+}
+
+func TestParseGCloudTime(t *testing.T) {
+	for _, value := range []string{"2025-06-01T12:00:00.123456Z", "2025-06-01T12:00:00"} {
+		if _, err := parseGCloudTime(value); err != nil {
+			t.Errorf("parseGCloudTime(%q) error = %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "01/06/2025 12:00"} {
+		if got, err := parseGCloudTime(value); err == nil {
+			t.Errorf("parseGCloudTime(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestDeletionOrderIndexesFirst(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	child := testImage("sha256:amd64-old", time.Hour, now)
+	index := testImage("sha256:index-old", time.Hour, now, "old")
+	index.Children = []string{child.Version}
+	emptyIndex := testImage("sha256:index-empty", time.Hour, now)
+	emptyIndex.MediaType = "application/vnd.oci.image.index.v1+json"
+	kept := testImage("sha256:kept", time.Hour, now, "latest")
+
+	decisions := []CleanupDecision{
+		{Image: child, Delete: true},
+		{Image: kept},
+		{Image: index, Delete: true},
+		{Image: emptyIndex, Delete: true},
+	}
+
+	var got []string
+	for _, d := range deletionOrder(decisions) {
+		got = append(got, d.Image.Version)
+	}
+	want := []string{"sha256:index-old", "sha256:index-empty", "sha256:amd64-old"}
+	if len(got) != len(want) {
+		t.Fatalf("deletionOrder() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("deletionOrder() = %v, want %v", got, want)
+			break
+		}
+	}
+}
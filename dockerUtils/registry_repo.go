@@ -0,0 +1,289 @@
+package dockerUtils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"DevOps/gcpUtils"
+
+	"github.com/rs/zerolog"
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Artifact Registry repository modes (values of the gcloud --mode flag).
+const (
+	RepoModeStandard = "standard-repository"
+	RepoModeRemote   = "remote-repository"
+	RepoModeVirtual  = "virtual-repository"
+)
+
+var (
+	ErrRepoNotFound         = errors.New("artifact registry repository not found")
+	ErrRepoPermissionDenied = errors.New("permission denied on artifact registry repository")
+)
+
+// RepoSettings holds the desired settings of the Artifact Registry repository.
+// Zero values mean "gcloud default" and are not checked for drift.
+type RepoSettings struct {
+	Description string
+
+	Mode             string // RepoModeStandard / RepoModeRemote / RepoModeVirtual
+	RemoteDockerRepo string // remote mode only, e.g. "DOCKER-HUB"
+	UpstreamPolicy   string // virtual mode only - path to upstream policy JSON file
+
+	ImmutableTags bool
+	KMSKey        string // projects/.../locations/.../keyRings/.../cryptoKeys/...
+	Labels        map[string]string
+
+	CleanupPolicyFile   string // path to cleanup policies JSON file (gcloud format)
+	CleanupPolicyDryRun bool
+}
+
+// RepoDrift describes a single setting that differs between the existing repository and RepoSettings.
+type RepoDrift struct {
+	Field    string
+	Desired  string
+	Existing string
+}
+
+// ההגדרות של ה-Repository הקיים שנבדקות ל-drift (מתוך ה-Artifact Registry API)
+type repoDescription struct {
+	Name         string
+	Description  string
+	Mode         string // STANDARD_REPOSITORY / REMOTE_REPOSITORY / VIRTUAL_REPOSITORY
+	KMSKeyName   string
+	Labels       map[string]string
+	DockerConfig struct {
+		ImmutableTags bool
+	}
+	CleanupPolicies     map[string]artifactregistry.CleanupPolicy
+	CleanupPolicyDryRun bool
+}
+
+// classifyRepoError ממפה את קוד השגיאה של ה-API לשגיאה מוגדרת
+func classifyRepoError(err error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.Code {
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrRepoPermissionDenied, err)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %v", ErrRepoNotFound, err)
+	default:
+		return err
+	}
+}
+
+// repoResourceName - השם המלא של ה-Repository ב-API
+func repoResourceName(cfg PushConfig) string {
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s", cfg.ProjectID, cfg.Region, cfg.RepoName)
+}
+
+// describeGCPRepo מחזיר את ההגדרות של ה-Repository הקיים, או שגיאה מסווגת
+func describeGCPRepo(log *zerolog.Logger, cfg PushConfig) (*repoDescription, error) {
+	ctx := context.Background()
+	opts, err := gcpUtils.ClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Artifact Registry client: %w", err)
+	}
+
+	name := repoResourceName(cfg)
+	log.Debug().Str("repo", name).Msg("🔍 Describing Artifact Registry repository")
+	repo, err := svc.Projects.Locations.Repositories.Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, classifyRepoError(err)
+	}
+
+	desc := &repoDescription{
+		Name:                repo.Name,
+		Description:         repo.Description,
+		Mode:                repo.Mode,
+		KMSKeyName:          repo.KmsKeyName,
+		Labels:              repo.Labels,
+		CleanupPolicies:     repo.CleanupPolicies,
+		CleanupPolicyDryRun: repo.CleanupPolicyDryRun,
+	}
+	if repo.DockerConfig != nil {
+		desc.DockerConfig.ImmutableTags = repo.DockerConfig.ImmutableTags
+	}
+	return desc, nil
+}
+
+// createRepoArgs בונה את הארגומנטים ל-gcloud artifacts repositories create לפי ההגדרות
+func createRepoArgs(cfg PushConfig) []string {
+	s := cfg.Repo
+
+	description := s.Description
+	if description == "" {
+		description = "Auto-created by build script"
+	}
+
+	args := []string{
+		"artifacts", "repositories", "create",
+		cfg.RepoName,
+		"--repository-format=docker",
+		"--project", cfg.ProjectID,
+		"--location", cfg.Region,
+		"--description=" + description,
+	}
+
+	if s.Mode != "" {
+		args = append(args, "--mode="+s.Mode)
+	}
+	if s.RemoteDockerRepo != "" {
+		args = append(args, "--remote-docker-repo="+s.RemoteDockerRepo)
+	}
+	if s.UpstreamPolicy != "" {
+		args = append(args, "--upstream-policy-file="+s.UpstreamPolicy)
+	}
+	if s.ImmutableTags {
+		args = append(args, "--immutable-tags")
+	}
+	if s.KMSKey != "" {
+		args = append(args, "--kms-key="+s.KMSKey)
+	}
+	if len(s.Labels) > 0 {
+		args = append(args, "--labels="+formatLabels(s.Labels))
+	}
+	if s.CleanupPolicyFile != "" {
+		args = append(args, "--cleanup-policy-file="+s.CleanupPolicyFile)
+		if s.CleanupPolicyDryRun {
+			args = append(args, "--cleanup-policy-dry-run")
+		} else {
+			args = append(args, "--no-cleanup-policy-dry-run")
+		}
+	}
+
+	return args
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// cleanupPolicyNames קורא את שמות ה-policies מקובץ ה-cleanup policy
+func cleanupPolicyNames(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse cleanup policy file %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// detectRepoDrift compares the existing repository with the desired settings.
+// Only settings that were explicitly configured are compared.
+func detectRepoDrift(desired RepoSettings, existing *repoDescription) ([]RepoDrift, error) {
+	var drift []RepoDrift
+
+	add := func(field, want, got string) {
+		if want != got {
+			drift = append(drift, RepoDrift{Field: field, Desired: want, Existing: got})
+		}
+	}
+
+	if desired.Description != "" {
+		add("description", desired.Description, existing.Description)
+	}
+	if desired.Mode != "" {
+		// ה-API מחזיר STANDARD_REPOSITORY כשהדגל של gcloud הוא standard-repository
+		add("mode", strings.ToUpper(strings.ReplaceAll(desired.Mode, "-", "_")), existing.Mode)
+	}
+	if desired.ImmutableTags {
+		add("immutableTags", "true", fmt.Sprint(existing.DockerConfig.ImmutableTags))
+	}
+	if desired.KMSKey != "" {
+		add("kmsKey", desired.KMSKey, existing.KMSKeyName)
+	}
+	if len(desired.Labels) > 0 && !reflect.DeepEqual(desired.Labels, existing.Labels) {
+		drift = append(drift, RepoDrift{
+			Field:    "labels",
+			Desired:  formatLabels(desired.Labels),
+			Existing: formatLabels(existing.Labels),
+		})
+	}
+	if desired.CleanupPolicyFile != "" {
+		want, err := cleanupPolicyNames(desired.CleanupPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		got := make([]string, 0, len(existing.CleanupPolicies))
+		for name := range existing.CleanupPolicies {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+
+		add("cleanupPolicies", strings.Join(want, ","), strings.Join(got, ","))
+		add("cleanupPolicyDryRun", fmt.Sprint(desired.CleanupPolicyDryRun), fmt.Sprint(existing.CleanupPolicyDryRun))
+	}
+
+	return drift, nil
+}
+
+// CheckGCPRepoDrift reports differences between the existing repository and cfg.Repo.
+func CheckGCPRepoDrift(log *zerolog.Logger, cfg PushConfig) ([]RepoDrift, error) {
+	existing, err := describeGCPRepo(log, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	drift, err := detectRepoDrift(cfg.Repo, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	logRepoDrift(log, cfg, drift)
+	return drift, nil
+}
+
+func logRepoDrift(log *zerolog.Logger, cfg PushConfig, drift []RepoDrift) {
+	if len(drift) == 0 {
+		log.Info().Str("repo", cfg.RepoName).Msg("✅ Repository settings match desired configuration")
+		return
+	}
+
+	for _, d := range drift {
+		log.Warn().
+			Str("repo", cfg.RepoName).
+			Str("field", d.Field).
+			Str("desired", d.Desired).
+			Str("existing", d.Existing).
+			Msg("⚠️ Repository setting drift detected")
+	}
+}
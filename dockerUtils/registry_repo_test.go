@@ -0,0 +1,194 @@
+package dockerUtils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestClassifyRepoError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden, Message: "Permission denied"}, ErrRepoPermissionDenied},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound, Message: "Requested entity was not found."}, ErrRepoNotFound},
+		{"wrapped not found", fmt.Errorf("describe: %w", &googleapi.Error{Code: http.StatusNotFound}), ErrRepoNotFound},
+		{"server error", &googleapi.Error{Code: http.StatusInternalServerError, Message: "404 in message"}, nil},
+		// טקסט שנראה כמו קוד שגיאה לא מספיק
+		{"plain text", errors.New("403 PERMISSION_DENIED: repository not found"), nil},
+	}
+	for _, tt := range tests {
+		got := classifyRepoError(tt.err)
+		for _, sentinel := range []error{ErrRepoPermissionDenied, ErrRepoNotFound} {
+			if is := errors.Is(got, sentinel); is != (sentinel == tt.want) {
+				t.Errorf("%s: errors.Is(%v, %v) = %v", tt.name, got, sentinel, is)
+			}
+		}
+		if !errors.Is(got, tt.err) && tt.want == nil {
+			t.Errorf("%s: unclassified error changed to %v", tt.name, got)
+		}
+	}
+}
+
+func TestRepoResourceName(t *testing.T) {
+	cfg := PushConfig{ProjectID: "my-project", Region: "europe-west1", RepoName: "images"}
+	want := "projects/my-project/locations/europe-west1/repositories/images"
+	if got := repoResourceName(cfg); got != want {
+		t.Errorf("repoResourceName() = %q, want %q", got, want)
+	}
+}
+
+func TestCreateRepoArgs(t *testing.T) {
+	base := []string{
+		"artifacts", "repositories", "create", "images",
+		"--repository-format=docker",
+		"--project", "my-project",
+		"--location", "europe-west1",
+	}
+	cfg := PushConfig{ProjectID: "my-project", Region: "europe-west1", RepoName: "images"}
+
+	tests := []struct {
+		name string
+		repo RepoSettings
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"--description=Auto-created by build script"},
+		},
+		{
+			name: "standard with every setting",
+			repo: RepoSettings{
+				Description:       "app images",
+				Mode:              RepoModeStandard,
+				ImmutableTags:     true,
+				KMSKey:            "projects/p/locations/europe-west1/keyRings/r/cryptoKeys/k",
+				Labels:            map[string]string{"team": "web", "environment": "prod"},
+				CleanupPolicyFile: "policy.json",
+			},
+			want: []string{
+				"--description=app images",
+				"--mode=standard-repository",
+				"--immutable-tags",
+				"--kms-key=projects/p/locations/europe-west1/keyRings/r/cryptoKeys/k",
+				"--labels=environment=prod,team=web",
+				"--cleanup-policy-file=policy.json",
+				"--no-cleanup-policy-dry-run",
+			},
+		},
+		{
+			name: "remote with dry-run cleanup",
+			repo: RepoSettings{
+				Mode:                RepoModeRemote,
+				RemoteDockerRepo:    "DOCKER-HUB",
+				CleanupPolicyFile:   "policy.json",
+				CleanupPolicyDryRun: true,
+			},
+			want: []string{
+				"--description=Auto-created by build script",
+				"--mode=remote-repository",
+				"--remote-docker-repo=DOCKER-HUB",
+				"--cleanup-policy-file=policy.json",
+				"--cleanup-policy-dry-run",
+			},
+		},
+		{
+			name: "virtual",
+			repo: RepoSettings{Mode: RepoModeVirtual, UpstreamPolicy: "upstreams.json"},
+			want: []string{
+				"--description=Auto-created by build script",
+				"--mode=virtual-repository",
+				"--upstream-policy-file=upstreams.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		cfg.Repo = tt.repo
+		want := append(append([]string{}, base...), tt.want...)
+		if got := createRepoArgs(cfg); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: createRepoArgs() =\n%v\nwant\n%v", tt.name, got, want)
+		}
+	}
+}
+
+func TestDetectRepoDrift(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	policy := `[{"name":"keep-releases","action":{"type":"Keep"}},{"name":"delete-old","action":{"type":"Delete"}}]`
+	if err := os.WriteFile(policyFile, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := &repoDescription{
+		Description: "app images",
+		Mode:        "STANDARD_REPOSITORY",
+		KMSKeyName:  "projects/p/locations/l/keyRings/r/cryptoKeys/old",
+		Labels:      map[string]string{"environment": "dev"},
+		CleanupPolicies: map[string]artifactregistry.CleanupPolicy{
+			"delete-old": {},
+		},
+		CleanupPolicyDryRun: true,
+	}
+
+	tests := []struct {
+		name    string
+		desired RepoSettings
+		want    []string // השדות עם סטייה
+	}{
+		{"nothing configured", RepoSettings{}, nil},
+		{
+			name:    "matching settings",
+			desired: RepoSettings{Description: "app images", Mode: RepoModeStandard, Labels: map[string]string{"environment": "dev"}},
+		},
+		{
+			name: "every setting drifted",
+			desired: RepoSettings{
+				Description:       "other",
+				Mode:              RepoModeRemote,
+				ImmutableTags:     true,
+				KMSKey:            "projects/p/locations/l/keyRings/r/cryptoKeys/new",
+				Labels:            map[string]string{"environment": "prod"},
+				CleanupPolicyFile: policyFile,
+			},
+			want: []string{"description", "mode", "immutableTags", "kmsKey", "labels", "cleanupPolicies", "cleanupPolicyDryRun"},
+		},
+	}
+	for _, tt := range tests {
+		drift, err := detectRepoDrift(tt.desired, existing)
+		if err != nil {
+			t.Fatalf("%s: detectRepoDrift() error = %v", tt.name, err)
+		}
+		var got []string
+		for _, d := range drift {
+			got = append(got, d.Field)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: drift fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// הערכים בדוח: שמות ה-policies ממוינים, mode בפורמט של ה-API
+	drift, _ := detectRepoDrift(RepoSettings{Mode: RepoModeVirtual, CleanupPolicyFile: policyFile, CleanupPolicyDryRun: true}, existing)
+	want := []RepoDrift{
+		{Field: "mode", Desired: "VIRTUAL_REPOSITORY", Existing: "STANDARD_REPOSITORY"},
+		{Field: "cleanupPolicies", Desired: "delete-old,keep-releases", Existing: "delete-old"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("detectRepoDrift() = %+v, want %+v", drift, want)
+	}
+}
+
+func TestDetectRepoDriftMissingPolicyFile(t *testing.T) {
+	desired := RepoSettings{CleanupPolicyFile: filepath.Join(t.TempDir(), "missing.json")}
+	if _, err := detectRepoDrift(desired, &repoDescription{}); err == nil {
+		t.Error("detectRepoDrift() with a missing policy file: error = nil, want an error")
+	}
+}
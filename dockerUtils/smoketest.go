@@ -0,0 +1,239 @@
+package dockerUtils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type HealthCheckType string
+
+const (
+	HealthCheckHTTP HealthCheckType = "http" // GET HealthURL returns ExpectedStatus
+	HealthCheckTCP  HealthCheckType = "tcp"  // TCPAddress accepts connections
+	HealthCheckExit HealthCheckType = "exit" // container exits with ExpectedExitCode
+)
+
+// SmokeTestConfig describes how to run the freshly built image locally before pushing it.
+type SmokeTestConfig struct {
+	Env     map[string]string
+	Ports   []string // "8080:80" (host:container)
+	Command []string // overrides the image CMD
+
+	HealthCheck      HealthCheckType
+	HealthURL        string // http, e.g. "http://localhost:8080/healthz"
+	ExpectedStatus   int    // http, defaults to 200
+	TCPAddress       string // tcp, e.g. "localhost:8080"
+	ExpectedExitCode int    // exit
+
+	Timeout time.Duration // defaults to 60s
+}
+
+var containerNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// containerState מחזיר את הסטטוס (running/exited/...) ואת קוד היציאה של הקונטיינר
+func containerState(log *zerolog.Logger, name string) (string, int, error) {
+	out, err := RunCommandOutput(log, "docker", "inspect", "-f", "{{.State.Status}} {{.State.ExitCode}}", name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("unexpected docker inspect output: %q", out)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, err
+	}
+	return fields[0], code, nil
+}
+
+// captureContainerLogs מעביר את הלוגים של הקונטיינר (stdout+stderr) ל-log stream
+func captureContainerLogs(log *zerolog.Logger, name string) {
+	out, err := exec.Command("docker", "logs", name).CombinedOutput()
+	if err != nil {
+		log.Warn().Err(err).Str("container", name).Msg("⚠️ Failed to read container logs")
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		log.Info().Str("container", name).Str("line", line).Msg("📜 Container log")
+	}
+}
+
+// checkHealth מבצע בדיקה בודדת. מחזיר true כשהבדיקה עברה.
+func checkHealth(log *zerolog.Logger, name string, cfg SmokeTestConfig) (bool, error) {
+	state, exitCode, err := containerState(log, name)
+	if err != nil {
+		return false, err
+	}
+
+	if cfg.HealthCheck == HealthCheckExit {
+		if state != "exited" {
+			return false, nil
+		}
+		if exitCode != cfg.ExpectedExitCode {
+			return false, fmt.Errorf("container exited with code %d (expected %d)", exitCode, cfg.ExpectedExitCode)
+		}
+		return true, nil
+	}
+
+	// בבדיקות http/tcp - קונטיינר שנפל הוא כישלון מיידי
+	if state != "running" && state != "created" {
+		return false, fmt.Errorf("container is %s (exit code %d) before becoming healthy", state, exitCode)
+	}
+
+	switch cfg.HealthCheck {
+	case HealthCheckHTTP:
+		client := http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get(cfg.HealthURL)
+		if err != nil {
+			return false, nil
+		}
+		resp.Body.Close()
+		return resp.StatusCode == cfg.ExpectedStatus, nil
+
+	case HealthCheckTCP:
+		conn, err := net.DialTimeout("tcp", cfg.TCPAddress, 5*time.Second)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unsupported health check type %q", cfg.HealthCheck)
+	}
+}
+
+// RunSmokeTest runs the image locally, waits for the configured health check and
+// copies the container logs into the log stream. The container is always removed.
+func RunSmokeTest(log *zerolog.Logger, image string, cfg SmokeTestConfig) error {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+	if cfg.ExpectedStatus == 0 {
+		cfg.ExpectedStatus = http.StatusOK
+	}
+	switch cfg.HealthCheck {
+	case HealthCheckHTTP:
+		if cfg.HealthURL == "" {
+			return errors.New("smoke test: http health check requires HealthURL")
+		}
+	case HealthCheckTCP:
+		if cfg.TCPAddress == "" {
+			return errors.New("smoke test: tcp health check requires TCPAddress")
+		}
+	case HealthCheckExit:
+	default:
+		return fmt.Errorf("smoke test: unsupported health check type %q", cfg.HealthCheck)
+	}
+
+	name := fmt.Sprintf("smoke-%s-%d", containerNameInvalid.ReplaceAllString(image, "-"), time.Now().Unix())
+
+	log.Info().
+		Str("image", image).
+		Str("container", name).
+		Str("check", string(cfg.HealthCheck)).
+		Dur("timeout", cfg.Timeout).
+		Msg("🧪 Starting local smoke test...")
+
+	// תמיד: איסוף לוגים ומחיקת הקונטיינר. נרשם לפי השם לפני ה-run - גם כש-docker run נכשל
+	// אחרי שהקונטיינר כבר נוצר (למשל port תפוס), הוא לא נשאר מאחור.
+	// בלי --rm: בבדיקת exit צריך לקרוא את קוד היציאה והלוגים אחרי שהקונטיינר יצא
+	defer func() {
+		captureContainerLogs(log, name)
+		if err := RunCommand(log, "docker", "rm", "-f", name); err != nil {
+			log.Warn().Str("container", name).Msg("⚠️ Failed to remove smoke test container")
+		}
+	}()
+
+	args := []string{"run", "-d", "--name", name}
+
+	// ערכי ה-Env עוברים בסביבה של docker CLI (-e KEY בלי ערך) - כך הם לא בשורת הפקודה ולא בלוג
+	envKeys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	env := make([]string, 0, len(envKeys))
+	for _, k := range envKeys {
+		args = append(args, "-e", k)
+		env = append(env, fmt.Sprintf("%s=%s", k, cfg.Env[k]))
+	}
+	for _, p := range cfg.Ports {
+		args = append(args, "-p", p)
+	}
+	args = append(args, image)
+	args = append(args, cfg.Command...)
+
+	if err := RunCommandWithEnv(log, env, "docker", args...); err != nil {
+		log.Error().Str("image", image).Msg("❌ Failed to start smoke test container")
+		return err
+	}
+
+	deadline := time.Now().Add(cfg.Timeout)
+	for time.Now().Before(deadline) {
+		ok, err := checkHealth(log, name, cfg)
+		if err != nil {
+			log.Error().Err(err).Str("container", name).Msg("❌ Smoke test failed")
+			return err
+		}
+		if ok {
+			log.Info().Str("image", image).Msg("✅ Smoke test passed")
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+		log.Debug().Str("container", name).Msg("Retrying smoke test health check...")
+	}
+
+	err := fmt.Errorf("smoke test timed out after %s", cfg.Timeout)
+	log.Error().Err(err).Str("image", image).Msg("❌ 🛑 Smoke test did not become healthy")
+	return err
+}
+
+// CheckURL polls a deployed service (e.g. a Cloud Run URL from Terraform outputs)
+// until it answers with expectedStatus or timeout passes.
+func CheckURL(log *zerolog.Logger, url string, expectedStatus int, timeout time.Duration) error {
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+
+	log.Info().Str("url", url).Int("expected_status", expectedStatus).Msg("🧪 Checking deployed service...")
+
+	client := http.Client{Timeout: 5 * time.Second}
+	deadline := time.Now().Add(timeout)
+	lastStatus := 0
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			lastStatus = resp.StatusCode
+			if resp.StatusCode == expectedStatus {
+				log.Info().Str("url", url).Msg("✅ Deployed service is healthy")
+				return nil
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	err := fmt.Errorf("service %s did not return %d within %s (last status %d)", url, expectedStatus, timeout, lastStatus)
+	log.Error().Err(err).Msg("❌ Deployed service check failed")
+	return err
+}
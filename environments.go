@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"DevOps/dockerUtils"
+	"DevOps/gcpUtils"
+	"DevOps/tfUtils"
+)
+
+// Environment מגדיר סביבה אחת (dev / staging / prod) - פרויקט, אזור, registry ו-Terraform משלה
+type Environment struct {
+	Name            string
+	ProjectID       string
+	Region          string
+	RepoName        string
+	TerraformDir    string
+	VarFile         string
+	BackendVarsFile string
+	Workspace       string // Terraform workspace (ריק = default). מאפשר כמה סביבות על אותה תיקייה
+
+	// Repo - הגדרות ה-Artifact Registry repository: ביצירה וב-repo-drift
+	Repo dockerUtils.RepoSettings
+
+	// Stacks - כמה תיקיות Terraform עם תלויות. ריק = TerraformDir יחיד
+	Stacks []tfUtils.Stack
+}
+
+// placeholderProjectPrefix - מזהה פרויקט שעוד לא הוגדר. סביבה כזו נכשלת מיד, לפני כל הרצה
+const placeholderProjectPrefix = "CHANGE-ME"
+
+// Environments - כל הסביבות המוכרות. dev משתמשת בקבועים הקיימים
+var Environments = map[string]Environment{
+	"dev": {
+		Name:            "dev",
+		ProjectID:       ProjectID,
+		Region:          Region,
+		RepoName:        RepoName,
+		TerraformDir:    TerraformDir,
+		VarFile:         VarFile,
+		BackendVarsFile: BackendVarsFile,
+		Repo: dockerUtils.RepoSettings{
+			Mode:   dockerUtils.RepoModeStandard,
+			Labels: map[string]string{"environment": "dev"},
+		},
+	},
+	"staging": {
+		Name:            "staging",
+		ProjectID:       "CHANGE-ME-staging-project",
+		Region:          Region,
+		RepoName:        RepoName,
+		TerraformDir:    "environments/staging",
+		VarFile:         VarFile,
+		BackendVarsFile: BackendVarsFile,
+		Repo: dockerUtils.RepoSettings{
+			Mode:          dockerUtils.RepoModeStandard,
+			ImmutableTags: true,
+			Labels:        map[string]string{"environment": "staging"},
+		},
+	},
+	"prod": {
+		Name:            "prod",
+		ProjectID:       "CHANGE-ME-prod-project",
+		Region:          Region,
+		RepoName:        RepoName,
+		TerraformDir:    "environments/prod",
+		VarFile:         VarFile,
+		BackendVarsFile: BackendVarsFile,
+		Repo: dockerUtils.RepoSettings{
+			Mode:          dockerUtils.RepoModeStandard,
+			ImmutableTags: true,
+			Labels:        map[string]string{"environment": "prod"},
+		},
+	},
+}
+
+// PushConfig מחזיר את הגדרות ה-push ל-Artifact Registry של הסביבה
+func (e Environment) PushConfig() dockerUtils.PushConfig {
+	return dockerUtils.PushConfig{
+		Registry:  dockerUtils.RegistryGCP,
+		ImageName: ImageName,
+		Tag:       "latest",
+		ProjectID: e.ProjectID,
+		Region:    e.Region,
+		RepoName:  e.RepoName,
+		Repo:      e.repoSettings(),
+	}
+}
+
+// repoSettings - הגדרות ה-repository של הסביבה, עם -repo-kms-key / -repo-cleanup-policy אם הועברו
+func (e Environment) repoSettings() dockerUtils.RepoSettings {
+	settings := e.Repo
+	if *repoKMSKey != "" {
+		settings.KMSKey = *repoKMSKey
+	}
+	if *repoCleanupPolicy != "" {
+		settings.CleanupPolicyFile = *repoCleanupPolicy
+		settings.CleanupPolicyDryRun = *repoCleanupDryRun
+	}
+	return settings
+}
+
+// שמות ה-outputs של Terraform שה-pipeline משתמש בהם
+const (
+	OutputRepositoryName = "repository_name" // ה-Artifact Registry repository שנוצר
+	OutputServiceURL     = "service_url"     // כתובת ה-Cloud Run לבדיקת smoke אחרי deploy
+)
+
+// PushConfigFromOutputs - הגדרות ה-push של הסביבה, עם ה-repository ש-Terraform יצר (אם יש)
+func (e Environment) PushConfigFromOutputs(outputs tfUtils.Outputs) dockerUtils.PushConfig {
+	cfg := e.PushConfig()
+	if repo, ok := outputs.String(OutputRepositoryName); ok {
+		cfg.RepoName = repo
+	}
+	return cfg
+}
+
+// TerraformOptions מחזיר את הגדרות ה-Terraform של הסביבה
+func (e Environment) TerraformOptions(destroy bool) tfUtils.TerraformOptions {
+	return tfUtils.TerraformOptions{
+		ProjectID:       e.ProjectID,
+		Region:          e.Region,
+		TerraformDir:    e.TerraformDir,
+		VarFile:         e.VarFile,
+		BackendVarsFile: e.BackendVarsFile,
+		Destroy:         destroy,
+
+		Workspace:           e.workspace(),
+		ProtectedWorkspaces: protectedWorkspaces(),
+	}
+}
+
+// workspace - ה-workspace של הסביבה, או -workspace אם הועבר
+func (e Environment) workspace() string {
+	if *workspaceFlag != "" {
+		return *workspaceFlag
+	}
+	return e.Workspace
+}
+
+// stackOptions - האופציות של כל stack בסביבה לפי סדר התלויות (או TerraformDir יחיד)
+func (e Environment) stackOptions(destroy bool) ([]tfUtils.TerraformOptions, error) {
+	opts := e.TerraformOptions(destroy)
+	if len(e.Stacks) == 0 {
+		return []tfUtils.TerraformOptions{opts}, nil
+	}
+	return tfUtils.StackOptions(opts, e.Stacks, destroy)
+}
+
+// resolvedStackOptions - כמו stackOptions, על בסיס opts, עם ה-Inputs של כל stack ממולאים
+// מה-outputs הנוכחיים של ה-stacks שהוא תלוי בהם (drift / snapshots - בלי apply)
+func (e Environment) resolvedStackOptions(opts tfUtils.TerraformOptions) ([]tfUtils.TerraformOptions, error) {
+	if len(e.Stacks) == 0 {
+		return []tfUtils.TerraformOptions{opts}, nil
+	}
+	return tfUtils.ResolvedStackOptions(&log, opts, e.Stacks)
+}
+
+// validateStacks - לכל stack prefix משלו ל-state. נבדק בעלייה, לפני שמשהו רץ
+func validateStacks(envs []Environment) error {
+	for _, env := range envs {
+		if len(env.Stacks) == 0 {
+			continue
+		}
+		if err := tfUtils.ValidateStackPrefixes(&log, env.TerraformOptions(false), env.Stacks); err != nil {
+			return fmt.Errorf("environment %s: %w", env.Name, err)
+		}
+	}
+	return nil
+}
+
+// stack - האופציות של stack אחד לפי שם (ריק מותר רק בסביבה בלי stacks)
+func (e Environment) stack(name string) (tfUtils.TerraformOptions, error) {
+	if len(e.Stacks) == 0 {
+		return e.TerraformOptions(false), nil
+	}
+	for _, s := range e.Stacks {
+		if s.Name == name {
+			return s.Options(e.TerraformOptions(false)), nil
+		}
+	}
+	return tfUtils.TerraformOptions{}, fmt.Errorf("environment %s has multiple stacks - choose one with -stack", e.Name)
+}
+
+// selectEnvironments מפרק רשימה מופרדת בפסיקים ("dev,staging") לסביבות, לפי הסדר שנכתב
+func selectEnvironments(list string) ([]Environment, error) {
+	var selected []Environment
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		env, ok := Environments[name]
+		if !ok {
+			known := make([]string, 0, len(Environments))
+			for k := range Environments {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown environment %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		if strings.HasPrefix(env.ProjectID, placeholderProjectPrefix) {
+			return nil, fmt.Errorf("environment %q has no GCP project configured (ProjectID is still %q in environments.go)", name, env.ProjectID)
+		}
+		selected = append(selected, env)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no environment selected")
+	}
+	return selected, nil
+}
+
+// isProtected - סביבה ברשימת -protected-envs לא ניתנת ל-destroy
+func isProtected(name string) bool {
+	for _, p := range strings.Split(*protectedEnvs, ",") {
+		if strings.TrimSpace(p) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedWorkspaces - ה-workspaces ברשימת -protected-workspaces
+func protectedWorkspaces() []string {
+	var names []string
+	for _, p := range strings.Split(*protectedWorkspacesFlag, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			names = append(names, p)
+		}
+	}
+	return names
+}
+
+// EnvironmentResult - התוצאה של הרצת ה-pipeline על סביבה אחת
+type EnvironmentResult struct {
+	Environment string
+	ProjectID   string
+	Duration    time.Duration
+	Err         error
+}
+
+// useEnvironment מפנה את שכבת ה-GCP לפרויקט של הסביבה
+func useEnvironment(env Environment) {
+	cfg := gcpUtils.CurrentConfig()
+	cfg.ProjectID = env.ProjectID
+	gcpUtils.Configure(cfg)
+}
+
+// runEnvironments מריץ את אותו pipeline על כל הסביבות ברצף. כישלון בסביבה אחת לא עוצר את הבאות
+func runEnvironments(envs []Environment, pipeline func(env Environment) error) []EnvironmentResult {
+	var results []EnvironmentResult
+
+	for _, env := range envs {
+		log.Info().Str("environment", env.Name).Str("project", env.ProjectID).Msg("🌍 Running pipeline for environment")
+		useEnvironment(env)
+
+		start := time.Now()
+		err := pipeline(env)
+		results = append(results, EnvironmentResult{
+			Environment: env.Name,
+			ProjectID:   env.ProjectID,
+			Duration:    time.Since(start),
+			Err:         err,
+		})
+	}
+
+	// סיכום לכל סביבה
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			log.Error().Err(r.Err).Str("environment", r.Environment).Str("project", r.ProjectID).
+				Dur("duration", r.Duration).Msg("❌ Environment failed")
+		} else {
+			log.Info().Str("environment", r.Environment).Str("project", r.ProjectID).
+				Dur("duration", r.Duration).Msg("✅ Environment succeeded")
+		}
+	}
+	log.Info().Int("environments", len(results)).Int("failed", failed).Msg("📊 Multi-environment run finished")
+
+	return results
+}
@@ -2,7 +2,9 @@ package gcpUtils
 
 import (
 	"bytes"
+	"fmt"
 	"os/exec"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -28,3 +30,25 @@ func RunCommand(log *zerolog.Logger, name string, args ...string) (string, error
 	return out.String(), err
 }
 
+// RunCommandOutput runs a command and returns only its stdout. stderr (warnings, the
+// "active configuration" banner of gcloud) is logged on failure and never mixed into the result.
+func RunCommandOutput(log *zerolog.Logger, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	log.Debug().Strs("args", args).Msgf("⚙️ Executing command: %s", name)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("output", stderr.String()).
+			Str("command", name).
+			Msg("❌ Command execution failed")
+		return stdout.String(), fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
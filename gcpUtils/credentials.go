@@ -0,0 +1,277 @@
+package gcpUtils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// סוגי ה-credentials (ערך השדה "type" בקובץ ה-JSON)
+const (
+	CredentialTypeUser            = "authorized_user"
+	CredentialTypeServiceAccount  = "service_account"
+	CredentialTypeExternalAccount = "external_account" // workload identity federation
+	CredentialTypeImpersonated    = "impersonated_service_account"
+	CredentialTypeMetadata        = "compute_metadata" // GCE / Cloud Run / GKE - ללא קובץ
+	CredentialTypeGcloud          = "gcloud"
+)
+
+// Config מרכז את הגדרות ההתחברות מול GCP
+type Config struct {
+	ProjectID      string
+	KeyFile        string   // service account key / external account config. ריק = ADC
+	Scopes         []string // ברירת מחדל: cloud-platform
+	GcloudFallback bool     // לאפשר נפילה ל-gcloud CLI כשאין credentials דרך ספריות Go
+	Headless       bool     // CI / קונטיינר: רק קובץ מפתח, בלי login אינטראקטיבי
+	ProjectScope   string   // ProjectScopeEnv (ברירת מחדל) / ProjectScopeConfiguration / ProjectScopeGlobal
+
+	// Preflight - APIs שחייבים להיות מופעלים והרשאות שחייבות להיות לקורא על הפרויקט
+	RequiredServices      []string // "artifactregistry" או "artifactregistry.googleapis.com"
+	EnableMissingServices bool
+	RequiredPermissions   []string
+
+	// service account שבשמו ירוצו Terraform, Artifact Registry ו-GCS (ריק = ללא impersonation)
+	ImpersonateServiceAccount string
+}
+
+// ההגדרות הפעילות - main קובע אותן דרך Configure (ומחליף את הפרויקט לכל סביבה).
+// currentMu שומר עליהן מול goroutines אחרים (token watcher)
+var (
+	currentMu sync.RWMutex
+	current   Config
+)
+
+// Configure sets the GCP settings used by the checks in this package.
+func Configure(cfg Config) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = cfg
+	resetCredentialsCache()
+}
+
+// CurrentConfig returns the GCP settings set by Configure.
+func CurrentConfig() Config {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// CredentialInfo describes the credentials that were resolved.
+type CredentialInfo struct {
+	Source      string // "key-file" / "adc" / "gcloud"
+	Type        string // אחד מ-CredentialType*
+	Principal   string // email של המשתמש / service account, או audience ב-federation
+	ProjectID   string
+	Expiry      time.Time
+	TokenSource oauth2.TokenSource
+}
+
+// קובץ ה-credentials - רק השדות שצריך כדי לזהות את ה-principal
+type credentialsFile struct {
+	Type                           string `json:"type"`
+	ClientEmail                    string `json:"client_email"`
+	Audience                       string `json:"audience"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	QuotaProjectID                 string `json:"quota_project_id"`
+}
+
+var impersonationURLEmail = regexp.MustCompile(`serviceAccounts/([^:/]+):generateAccessToken`)
+
+func scopes(cfg Config) []string {
+	if len(cfg.Scopes) > 0 {
+		return cfg.Scopes
+	}
+	return []string{cloudPlatformScope}
+}
+
+// findCredentials טוען credentials מקובץ מפורש או מ-ADC (GOOGLE_APPLICATION_CREDENTIALS,
+// קובץ ה-ADC של gcloud, או שרת ה-metadata)
+func findCredentials(ctx context.Context, cfg Config) (*google.Credentials, string, error) {
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read credentials file %s: %w", cfg.KeyFile, err)
+		}
+		creds, err := google.CredentialsFromJSON(ctx, data, scopes(cfg)...)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid credentials file %s: %w", cfg.KeyFile, err)
+		}
+		return creds, "key-file", nil
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx, scopes(cfg)...)
+	if err != nil {
+		return nil, "", err
+	}
+	return creds, "adc", nil
+}
+
+// tokenInfoEmail שואל את tokeninfo מי הבעלים של ה-access token.
+// ה-token נשלח בגוף ה-POST ולא ב-URL - כך הוא לא נשמר בלוגים של proxy / שרת
+func tokenInfoEmail(ctx context.Context, accessToken string) string {
+	form := url.Values{"access_token": {accessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"https://oauth2.googleapis.com/tokeninfo", strings.NewReader(form.Encode()))
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		Email string `json:"email"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+		return ""
+	}
+	return info.Email
+}
+
+// ResolveCredentials resolves credentials through the Go auth libraries (key file, ADC,
+// external account) and fetches a token to report the effective principal and expiry.
+func ResolveCredentials(ctx context.Context, log *zerolog.Logger, cfg Config) (*CredentialInfo, error) {
+	creds, source, err := findCredentials(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+
+	info := &CredentialInfo{
+		Source:      source,
+		Type:        CredentialTypeMetadata,
+		ProjectID:   creds.ProjectID,
+		Expiry:      token.Expiry,
+		TokenSource: creds.TokenSource,
+	}
+
+	if len(creds.JSON) > 0 {
+		var f credentialsFile
+		if err := json.Unmarshal(creds.JSON, &f); err == nil {
+			info.Type = f.Type
+			if info.ProjectID == "" {
+				info.ProjectID = f.QuotaProjectID
+			}
+
+			switch {
+			case f.ClientEmail != "":
+				info.Principal = f.ClientEmail
+			case f.ServiceAccountImpersonationURL != "":
+				if m := impersonationURLEmail.FindStringSubmatch(f.ServiceAccountImpersonationURL); m != nil {
+					info.Principal = m[1]
+				}
+			case f.Type == CredentialTypeExternalAccount:
+				info.Principal = f.Audience
+			}
+		}
+	}
+
+	if info.Principal == "" {
+		info.Principal = tokenInfoEmail(ctx, token.AccessToken)
+	}
+
+	log.Debug().
+		Str("source", info.Source).
+		Str("type", info.Type).
+		Str("principal", info.Principal).
+		Time("expiry", info.Expiry).
+		Msg("🔑 Resolved GCP credentials")
+
+	return info, nil
+}
+
+// LogCredentials prints the effective principal and token expiry.
+func LogCredentials(log *zerolog.Logger, info *CredentialInfo) {
+	event := log.Info().
+		Str("source", info.Source).
+		Str("type", info.Type).
+		Str("principal", info.Principal)
+	if !info.Expiry.IsZero() {
+		event = event.
+			Time("token_expiry", info.Expiry).
+			Dur("expires_in", time.Until(info.Expiry).Round(time.Second))
+	}
+	event.Msg("🔑 Using GCP credentials")
+}
+
+var errNoCredentials = errors.New("no GCP credentials found")
+
+// credentialsCacheMargin - credentials שמורים נחשבים תקפים עד זמן קצר לפני פקיעת ה-token
+const credentialsCacheMargin = time.Minute
+
+var (
+	credentialsCacheMu sync.Mutex
+	credentialsCache   *CredentialInfo
+)
+
+// resetCredentialsCache - נקרא כשההגדרות משתנות (Configure)
+func resetCredentialsCache() {
+	credentialsCacheMu.Lock()
+	defer credentialsCacheMu.Unlock()
+	credentialsCache = nil
+}
+
+// cachedCredentials מחזיר את ה-credentials השמורים אם ה-token שלהם עדיין בתוקף
+func cachedCredentials() *CredentialInfo {
+	credentialsCacheMu.Lock()
+	defer credentialsCacheMu.Unlock()
+	if credentialsCache == nil || time.Until(credentialsCache.Expiry) < credentialsCacheMargin {
+		return nil
+	}
+	return credentialsCache
+}
+
+// currentCredentials מחזיר את ה-credentials לפי Configure, עם נפילה ל-gcloud רק אם התבקש.
+// תוצאה מוצלחת נשמרת עד סמוך לפקיעת ה-token - בלי resolve ו-tokeninfo בכל בדיקה
+func currentCredentials(log *zerolog.Logger) (*CredentialInfo, error) {
+	if info := cachedCredentials(); info != nil {
+		return info, nil
+	}
+
+	cfg := CurrentConfig()
+	info, err := ResolveCredentials(context.Background(), log, cfg)
+	if err == nil {
+		credentialsCacheMu.Lock()
+		credentialsCache = info
+		credentialsCacheMu.Unlock()
+		return info, nil
+	}
+
+	if !cfg.GcloudFallback {
+		log.Warn().Err(err).Msg("⚠️ Failed to resolve GCP credentials")
+		return nil, fmt.Errorf("%w: %v", errNoCredentials, err)
+	}
+
+	log.Warn().Err(err).Msg("⚠️ Native GCP credentials unavailable, falling back to gcloud CLI")
+	if !isGcloudAuthenticated(log) {
+		return nil, errNoCredentials
+	}
+
+	account, _ := configValue(log, "account")
+	return &CredentialInfo{
+		Source:    "gcloud",
+		Type:      CredentialTypeGcloud,
+		Principal: account,
+	}, nil
+}
@@ -1,6 +1,9 @@
 package gcpUtils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -9,7 +12,15 @@ import (
 )
 
 
+// IsGCPAuthenticated בודק שיש credentials תקינים דרך ספריות ה-Go (קובץ מפתח / ADC / federation).
+// gcloud נבדק רק אם הוגדר GcloudFallback.
 func IsGCPAuthenticated(log *zerolog.Logger) bool {
+    _, err := currentCredentials(log)
+    return err == nil
+}
+
+// isGcloudAuthenticated - הבדיקה הישנה מול gcloud CLI
+func isGcloudAuthenticated(log *zerolog.Logger) bool {
     // שלב 1: בדוק שיש חשבון פעיל
     out, err := RunCommand(
         log,
@@ -46,8 +57,19 @@ func GCPLogin(log *zerolog.Logger) error {
 }
 
 func IsGCPApplicationDefaultAuthenticated(log *zerolog.Logger) bool {
+    // ADC דרך ספריות Go - בדיוק מה ש-Terraform וה-SDK רואים
+    cfg := CurrentConfig()
+    _, err := ResolveCredentials(context.Background(), log, Config{Scopes: cfg.Scopes})
+    if err == nil {
+        return true
+    }
+    if !cfg.GcloudFallback {
+        log.Warn().Err(err).Msg("Application Default Credentials invalid or missing")
+        return false
+    }
+
     // נסה להוציא את ה-ADC token
-    _, err := RunCommand(
+    _, err = RunCommand(
         log,
         "gcloud",
         "auth",
@@ -68,24 +90,63 @@ func GCPApplicationDefaultLogin(log *zerolog.Logger) error {
 }
 
 
+// GetCurrentProject מחזיר את הפרויקט הפעיל: משתני סביבה, ואז הפרויקט של ה-credentials.
+// gcloud config נקרא רק אם הוגדר GcloudFallback.
 func GetCurrentProject(log *zerolog.Logger) (string, error) {
-	out, err := RunCommand(
-		log,
-		"gcloud",
-		"config",
-		"get-value",
-		"project",
-	)
+	for _, env := range []string{"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT"} {
+		if project := os.Getenv(env); project != "" {
+			return project, nil
+		}
+	}
 
-	if err != nil {
-		return "", err
+	cfg := CurrentConfig()
+	info, err := ResolveCredentials(context.Background(), log, cfg)
+	if err == nil && info.ProjectID != "" {
+		return info.ProjectID, nil
 	}
 
-	return strings.TrimSpace(out), nil
+	if !cfg.GcloudFallback {
+		// אין פרויקט מוגדר - RunGCPCheck יגדיר את הפרויקט הצפוי
+		return "", nil
+	}
+
+	return configValue(log, "project")
 }
 
+// SetProject בוחר את הפרויקט לפי Config.ProjectScope. ברירת המחדל משנה רק את
+// משתני הסביבה של התהליך הנוכחי; במצב global, RestoreProject מחזיר את הפרויקט הקודם ביציאה.
 func SetProject(log *zerolog.Logger, projectID string) error {
-    log.Info().Str("project", projectID).Msg("🔄 Switching GCP project and setting quota...")
+    scope := CurrentConfig().ProjectScope
+    if scope == "" {
+        scope = ProjectScopeEnv
+    }
+    log.Info().Str("project", projectID).Str("scope", scope).Msg("🔄 Switching GCP project and setting quota...")
+
+    switch scope {
+    case ProjectScopeEnv:
+        setProjectEnv(projectID)
+    case ProjectScopeConfiguration:
+        if err := setProjectConfiguration(log, projectID); err != nil {
+            log.Error().Err(err).Msg("❌ Failed to set up dedicated gcloud configuration")
+            return err
+        }
+        setProjectEnv(projectID)
+    case ProjectScopeGlobal:
+        return setProjectGlobal(log, projectID)
+    default:
+        return fmt.Errorf("unknown project scope %q", scope)
+    }
+
+    log.Info().Msg("✅ GCP Project and Quota are now set for this process")
+    return nil
+}
+
+// setProjectGlobal - ההתנהגות הישנה (opt-in בלבד): משנה את ה-gcloud config הגלובלי
+func setProjectGlobal(log *zerolog.Logger, projectID string) error {
+    log.Warn().Msg("⚠️ Global project scope - this changes the gcloud config for every terminal")
+
+    // שמירת הפרויקט הקודם לשחזור ביציאה
+    savePreviousProject(log)
 
     // 1. עדכון הפרויקט ב-gcloud config (עבור ה-CLI)
     _, err := RunCommand(
@@ -117,13 +178,15 @@ func SetProject(log *zerolog.Logger, projectID string) error {
 
     // 3. הגדרת משתני סביבה בזיכרון (עובד מעולה ב-Windows עבור התהליך הנוכחי)
     // זה מבטיח שכל כלי (כמו Terraform) שיורץ מהקוד הזה יזהה את הפרויקט מיד
-    os.Setenv("GOOGLE_CLOUD_PROJECT", projectID)
-    os.Setenv("GOOGLE_TERRAFORM_QUOTA_PROJECT", projectID)
+    setScopedEnv("GOOGLE_CLOUD_PROJECT", projectID)
+    setScopedEnv("GOOGLE_TERRAFORM_QUOTA_PROJECT", projectID)
 
     log.Info().Msg("✅ GCP Project and Quota are now synchronized")
     return nil
 }
 
+// WaitForGCPAuth ממתין להתחברות אחרי login. currentCredentials שומר תוצאה מוצלחת,
+// כך שהבדיקות אחרי ההמתנה לא חוזרות על ה-resolve וה-tokeninfo
 func WaitForGCPAuth(log *zerolog.Logger, timeout time.Duration) bool {
 	start := time.Now()
 
@@ -137,31 +200,76 @@ func WaitForGCPAuth(log *zerolog.Logger, timeout time.Duration) bool {
 }
 
 
+// RunGCPCheck מריץ את CheckGCP ועוצר את התהליך אם הסביבה לא מוכנה
 func RunGCPCheck(log *zerolog.Logger, expectedProject string) {
+	if err := CheckGCP(log, expectedProject); err != nil {
+		RestoreProject(log)
+		log.Fatal().Err(err).Msg("❌ GCP environment is not ready")
+	}
+}
+
+// CheckGCP מוודא התחברות, פרויקט, ADC ו-preflight. מחזיר שגיאה במקום לעצור את התהליך
+// (כך אפשר להריץ כמה סביבות ברצף ולהמשיך לסביבה הבאה)
+func CheckGCP(log *zerolog.Logger, expectedProject string) error {
 	log.Info().Msg("🔍 Checking GCP authentication and project...")
+	cfg := CurrentConfig()
+
+	// קובץ מפתח (-gcp-key-file) חל על כל הכלים, לא רק על ספריות ה-Go - Terraform קורא אותו מכאן
+	if cfg.KeyFile != "" {
+		setScopedEnv("GOOGLE_APPLICATION_CREDENTIALS", cfg.KeyFile)
+	}
 
 	// 1️⃣ Auth check
-	if !IsGCPAuthenticated(log) {
-		log.Warn().Msg("⚠️ Not authenticated to GCP")
+	if cfg.Headless {
+		// בלי דפדפן ובלי המתנה - או שיש מפתח תקין, או שנכשלים מיד
+		info, err := HeadlessAuth(log)
+		if err != nil {
+			log.Error().Err(err).Msg("❌ Headless GCP authentication failed")
+			return err
+		}
+		LogCredentials(log, info)
+	} else {
+		if !IsGCPAuthenticated(log) {
+			log.Warn().Msg("⚠️ Not authenticated to GCP")
+
+			// בלי fallback ל-gcloud, ספריות ה-Go קוראות את ה-ADC - לכן מתחברים אליו
+			login := GCPApplicationDefaultLogin
+			if cfg.GcloudFallback {
+				login = GCPLogin
+			}
 
-		if err := GCPLogin(log); err != nil {
-			log.Fatal().Err(err).Msg("❌ Failed to authenticate to GCP")
-			return
+			if err := login(log); err != nil {
+				log.Error().Err(err).Msg("❌ Failed to authenticate to GCP")
+				return err
+			}
+
+			if !WaitForGCPAuth(log, 60*time.Second) {
+				log.Error().Msg("❌ GCP authentication timeout")
+				return errors.New("GCP authentication timeout")
+			}
 		}
 
-		if !WaitForGCPAuth(log, 60*time.Second) {
-			log.Fatal().Msg("❌ GCP authentication timeout")
-			return
+		log.Info().Msg("✅ Authenticated to GCP")
+		if info, err := currentCredentials(log); err == nil {
+			LogCredentials(log, info)
 		}
 	}
 
-	log.Info().Msg("✅ Authenticated to GCP")
+	// 1️⃣.5 Impersonation - בדיקה מקדימה שמותר לנו, ורק אז כל הכלים עוברים ל-service account
+	if sa := cfg.ImpersonateServiceAccount; sa != "" {
+		if err := CheckImpersonationPermission(context.Background(), log, sa); err != nil {
+			log.Error().Err(err).Str("service_account", sa).Msg("❌ Cannot impersonate deploy service account")
+			return err
+		}
+		applyImpersonationEnv(sa)
+		log.Info().Str("service_account", sa).Msg("🎭 All GCP operations will run as impersonated service account")
+	}
 
 	// 2️⃣ Project check
 	currentProject, err := GetCurrentProject(log)
 	if err != nil {
-		log.Fatal().Err(err).Msg("❌ Failed to get current GCP project")
-		return
+		log.Error().Err(err).Msg("❌ Failed to get current GCP project")
+		return err
 	}
 
 	log.Info().
@@ -171,26 +279,31 @@ func RunGCPCheck(log *zerolog.Logger, expectedProject string) {
 
 	if currentProject != expectedProject {
 		log.Warn().Msg("⚠️ Active project does not match expected project")
-
-		if err := SetProject(log, expectedProject); err != nil {
-			log.Fatal().Err(err).Msg("❌ Failed to switch GCP project")
-			return
-		}
-
-		log.Info().Msg("✅ Project switched successfully")
 	} else {
 		log.Info().Msg("✅ Correct GCP project already active")
 	}
 
+	// גם כשהפרויקט כבר תואם (למשל מה-credentials) - gcloud ו-Terraform צריכים אותו במפורש
+	// (CLOUDSDK_CORE_PROJECT וכו'), אחרת הם נופלים ל-gcloud config הגלובלי
+	if err := SetProject(log, expectedProject); err != nil {
+		log.Error().Err(err).Msg("❌ Failed to switch GCP project")
+		return err
+	}
+
 	log.Info().Msg("🚀 GCP environment ready – continuing execution")
 
 	// 3️⃣ ADC check for Terraform / SDK
 	if !IsGCPApplicationDefaultAuthenticated(log) {
 		log.Warn().Msg("⚠️ ADC not authenticated")
 
+		if cfg.Headless {
+			log.Error().Msg("❌ Headless mode: Application Default Credentials are not usable (check GOOGLE_APPLICATION_CREDENTIALS)")
+			return errors.New("headless mode: Application Default Credentials are not usable")
+		}
+
 		if err := GCPApplicationDefaultLogin(log); err != nil {
-			log.Fatal().Err(err).Msg("❌ Failed to authenticate ADC")
-			return
+			log.Error().Err(err).Msg("❌ Failed to authenticate ADC")
+			return err
 		}
 
 		// אפשר גם כאן לחכות עד שהטוקן פעיל
@@ -205,4 +318,25 @@ func RunGCPCheck(log *zerolog.Logger, expectedProject string) {
 	}
 	log.Info().Msg("✅ Application Default Credentials ready")
 
+	// 4️⃣ Preflight - APIs והרשאות, לפני ש-Terraform נכשל באמצע
+	if len(cfg.RequiredServices) > 0 || len(cfg.RequiredPermissions) > 0 {
+		report, err := RunPreflight(log, expectedProject)
+		if err != nil {
+			log.Error().Err(err).Msg("❌ GCP preflight checks could not run")
+			return err
+		}
+		if !report.OK() {
+			log.Error().
+				Strs("missing_services", report.MissingServices()).
+				Strs("missing_permissions", report.MissingPermissions()).
+				Msg("❌ GCP preflight failed - fix the report above before continuing")
+			return errors.New("GCP preflight failed")
+		}
+		log.Info().Msg("✅ GCP preflight passed")
+	}
+
+	// 5️⃣ מעקב אחרי תוקף ה-token לאורך הרצות ארוכות (apply / push)
+	StartTokenWatcher(context.Background(), log)
+
+	return nil
 }
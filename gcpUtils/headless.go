@@ -0,0 +1,68 @@
+package gcpUtils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrHeadlessNoCredentials is returned in headless mode when no key file is configured.
+var ErrHeadlessNoCredentials = errors.New(
+	"headless mode: no credentials - set a key file (-gcp-key-file) or GOOGLE_APPLICATION_CREDENTIALS",
+)
+
+// headlessKeyFile מחזיר את קובץ המפתח: מההגדרות, ואם אין - מ-GOOGLE_APPLICATION_CREDENTIALS
+func headlessKeyFile() string {
+	if keyFile := CurrentConfig().KeyFile; keyFile != "" {
+		return keyFile
+	}
+	return os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+}
+
+// HeadlessAuth authenticates from a service account key (or external account config) without
+// ever prompting or opening a browser. It fails fast when credentials are missing or invalid.
+// On success, gcloud, Terraform and the Go SDKs all use the same credentials.
+func HeadlessAuth(log *zerolog.Logger) (*CredentialInfo, error) {
+	log.Info().Msg("🤖 Headless mode - authenticating without interactive login")
+
+	// gcloud לעולם לא ישאל שאלות בהרצה הזו
+	os.Setenv("CLOUDSDK_CORE_DISABLE_PROMPTS", "1")
+
+	keyFile := headlessKeyFile()
+	if keyFile == "" {
+		return nil, ErrHeadlessNoCredentials
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		return nil, fmt.Errorf("headless mode: credentials file %s is not readable: %w", keyFile, err)
+	}
+
+	cfg := CurrentConfig()
+	cfg.KeyFile = keyFile
+	info, err := ResolveCredentials(context.Background(), log, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("headless mode: invalid credentials in %s: %w", keyFile, err)
+	}
+
+	// Terraform וה-SDK קוראים את ה-ADC מהמשתנה הזה
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", keyFile)
+
+	// gcloud (Artifact Registry, configure-docker) - התחברות מאותו קובץ, בלי דפדפן
+	var args []string
+	switch info.Type {
+	case CredentialTypeServiceAccount:
+		args = []string{"auth", "activate-service-account", "--key-file=" + keyFile, "--quiet"}
+	case CredentialTypeExternalAccount, CredentialTypeImpersonated:
+		args = []string{"auth", "login", "--cred-file=" + keyFile, "--quiet"}
+	default:
+		return nil, fmt.Errorf("headless mode: unsupported credentials type %q in %s", info.Type, keyFile)
+	}
+	if _, err := RunCommand(log, "gcloud", args...); err != nil {
+		return nil, fmt.Errorf("headless mode: gcloud failed to use %s: %w", keyFile, err)
+	}
+
+	log.Info().Str("principal", info.Principal).Msg("✅ Headless authentication succeeded")
+	return info, nil
+}
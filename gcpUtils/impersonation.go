@@ -0,0 +1,85 @@
+package gcpUtils
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// ההרשאה ש-roles/iam.serviceAccountTokenCreator נותן ושנדרשת ל-impersonation
+const tokenCreatorPermission = "iam.serviceAccounts.getAccessToken"
+
+// callerClientOptions - ה-credentials של המשתמש עצמו (ללא impersonation)
+func callerClientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	creds, _, err := findCredentials(ctx, CurrentConfig())
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithTokenSource(creds.TokenSource)}, nil
+}
+
+// ClientOptions returns the options every Go GCP client in this project should use:
+// the configured credentials, impersonating Config.ImpersonateServiceAccount when set.
+func ClientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	cfg := CurrentConfig()
+	base, err := callerClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ImpersonateServiceAccount == "" {
+		return base, nil
+	}
+
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: cfg.ImpersonateServiceAccount,
+		Scopes:          scopes(cfg),
+	}, base...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate %s: %w", cfg.ImpersonateServiceAccount, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
+
+// CheckImpersonationPermission verifies that the caller may mint tokens for the target
+// service account (roles/iam.serviceAccountTokenCreator) before anything relies on it.
+func CheckImpersonationPermission(ctx context.Context, log *zerolog.Logger, serviceAccount string) error {
+	log.Info().Str("service_account", serviceAccount).Msg("🔍 Checking permission to impersonate service account...")
+
+	opts, err := callerClientOptions(ctx)
+	if err != nil {
+		return err
+	}
+	svc, err := iam.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create IAM client: %w", err)
+	}
+
+	resource := "projects/-/serviceAccounts/" + serviceAccount
+	resp, err := svc.Projects.ServiceAccounts.TestIamPermissions(resource, &iam.TestIamPermissionsRequest{
+		Permissions: []string{tokenCreatorPermission},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to test permissions on %s: %w", serviceAccount, err)
+	}
+
+	for _, p := range resp.Permissions {
+		if p == tokenCreatorPermission {
+			log.Info().Str("service_account", serviceAccount).Msg("✅ Caller can impersonate service account")
+			return nil
+		}
+	}
+	return fmt.Errorf("caller is missing %s on %s (grant roles/iam.serviceAccountTokenCreator)",
+		tokenCreatorPermission, serviceAccount)
+}
+
+// applyImpersonationEnv גורם ל-gcloud (Artifact Registry, configure-docker) ול-Terraform
+// (provider + GCS backend) לרוץ בשם ה-service account
+func applyImpersonationEnv(serviceAccount string) {
+	os.Setenv("CLOUDSDK_AUTH_IMPERSONATE_SERVICE_ACCOUNT", serviceAccount)
+	os.Setenv("GOOGLE_IMPERSONATE_SERVICE_ACCOUNT", serviceAccount)
+}
@@ -0,0 +1,250 @@
+package gcpUtils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1"
+)
+
+// batchGet של serviceusage מקבל עד 30 שירותים בכל קריאה
+const serviceUsageBatchSize = 30
+
+// ServiceStatus is the state of one required API on the project.
+type ServiceStatus struct {
+	Name       string
+	Enabled    bool
+	EnabledNow bool // הופעל בהרצה הזו
+}
+
+// PermissionStatus is whether the caller holds one required IAM permission.
+type PermissionStatus struct {
+	Name    string
+	Granted bool
+}
+
+// PreflightReport is the result of RunPreflight.
+type PreflightReport struct {
+	ProjectID   string
+	Services    []ServiceStatus
+	Permissions []PermissionStatus
+}
+
+// OK reports whether every required service is enabled and every permission is held.
+func (r *PreflightReport) OK() bool {
+	return len(r.MissingServices()) == 0 && len(r.MissingPermissions()) == 0
+}
+
+func (r *PreflightReport) MissingServices() []string {
+	var missing []string
+	for _, s := range r.Services {
+		if !s.Enabled {
+			missing = append(missing, s.Name)
+		}
+	}
+	return missing
+}
+
+func (r *PreflightReport) MissingPermissions() []string {
+	var missing []string
+	for _, p := range r.Permissions {
+		if !p.Granted {
+			missing = append(missing, p.Name)
+		}
+	}
+	return missing
+}
+
+// Log prints the report line by line to the log viewer.
+func (r *PreflightReport) Log(log *zerolog.Logger) {
+	for _, s := range r.Services {
+		switch {
+		case s.EnabledNow:
+			log.Info().Str("service", s.Name).Msg("🟢 API enabled now")
+		case s.Enabled:
+			log.Info().Str("service", s.Name).Msg("✅ API enabled")
+		default:
+			log.Error().Str("service", s.Name).Msg("❌ API not enabled")
+		}
+	}
+	for _, p := range r.Permissions {
+		if p.Granted {
+			log.Info().Str("permission", p.Name).Msg("✅ Permission granted")
+		} else {
+			log.Error().Str("permission", p.Name).Msg("❌ Permission missing")
+		}
+	}
+
+	event := log.Info()
+	if !r.OK() {
+		event = log.Error()
+	}
+	event.
+		Str("project", r.ProjectID).
+		Int("services", len(r.Services)).
+		Strs("missing_services", r.MissingServices()).
+		Int("permissions", len(r.Permissions)).
+		Strs("missing_permissions", r.MissingPermissions()).
+		Msg("📋 GCP preflight report")
+}
+
+// normalizeService - "run" -> "run.googleapis.com"
+func normalizeService(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return name + ".googleapis.com"
+}
+
+// checkServices מחזיר את מצב השירותים הנדרשים בפרויקט
+func checkServices(ctx context.Context, svc *serviceusage.Service, projectID string, names []string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	parent := "projects/" + projectID
+
+	for start := 0; start < len(names); start += serviceUsageBatchSize {
+		end := min(start+serviceUsageBatchSize, len(names))
+
+		var full []string
+		for _, n := range names[start:end] {
+			full = append(full, parent+"/services/"+n)
+		}
+
+		resp, err := svc.Services.BatchGet(parent).Names(full...).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query enabled services: %w", err)
+		}
+		for _, s := range resp.Services {
+			name := s.Name[strings.LastIndex(s.Name, "/")+1:]
+			enabled[name] = s.State == "ENABLED"
+		}
+	}
+	return enabled, nil
+}
+
+// enableServices מפעיל שירותים וממתין לסיום הפעולה
+func enableServices(ctx context.Context, log *zerolog.Logger, svc *serviceusage.Service, projectID string, names []string) error {
+	log.Info().Strs("services", names).Msg("⚙️ Enabling missing APIs...")
+
+	op, err := svc.Services.BatchEnable("projects/"+projectID, &serviceusage.BatchEnableServicesRequest{
+		ServiceIds: names,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to enable services: %w", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Minute)
+	for !op.Done {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for services to be enabled (operation %s)", op.Name)
+		}
+		time.Sleep(3 * time.Second)
+		if op, err = svc.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to poll enable operation: %w", err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("enable services failed: %s", op.Error.Message)
+	}
+	return nil
+}
+
+// checkPermissions משתמש ב-testIamPermissions כדי לדעת אילו הרשאות יש לקורא על הפרויקט
+func checkPermissions(ctx context.Context, opts []option.ClientOption, projectID string, permissions []string) (map[string]bool, error) {
+	svc, err := crm.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Resource Manager client: %w", err)
+	}
+
+	resp, err := svc.Projects.TestIamPermissions(projectID, &crm.TestIamPermissionsRequest{
+		Permissions: permissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to test IAM permissions: %w", err)
+	}
+
+	granted := map[string]bool{}
+	for _, p := range resp.Permissions {
+		granted[p] = true
+	}
+	return granted, nil
+}
+
+// RunPreflight verifies that the required APIs are enabled on projectID (optionally enabling
+// them) and that the caller - or the impersonated service account - holds the required permissions.
+func RunPreflight(log *zerolog.Logger, projectID string) (*PreflightReport, error) {
+	log.Info().Str("project", projectID).Msg("🛫 Running GCP preflight checks...")
+
+	ctx := context.Background()
+	cfg := CurrentConfig()
+	report := &PreflightReport{ProjectID: projectID}
+
+	opts, err := ClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. APIs
+	if len(cfg.RequiredServices) > 0 {
+		var names []string
+		for _, n := range cfg.RequiredServices {
+			names = append(names, normalizeService(n))
+		}
+		sort.Strings(names)
+
+		svc, err := serviceusage.NewService(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Service Usage client: %w", err)
+		}
+
+		enabled, err := checkServices(ctx, svc, projectID, names)
+		if err != nil {
+			return nil, err
+		}
+
+		var missing []string
+		for _, n := range names {
+			if !enabled[n] {
+				missing = append(missing, n)
+			}
+		}
+
+		enabledNow := map[string]bool{}
+		if len(missing) > 0 && cfg.EnableMissingServices {
+			if err := enableServices(ctx, log, svc, projectID, missing); err != nil {
+				return nil, err
+			}
+			for _, n := range missing {
+				enabled[n] = true
+				enabledNow[n] = true
+			}
+		}
+
+		for _, n := range names {
+			report.Services = append(report.Services, ServiceStatus{
+				Name:       n,
+				Enabled:    enabled[n],
+				EnabledNow: enabledNow[n],
+			})
+		}
+	}
+
+	// 2. IAM permissions
+	if len(cfg.RequiredPermissions) > 0 {
+		granted, err := checkPermissions(ctx, opts, projectID, cfg.RequiredPermissions)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range cfg.RequiredPermissions {
+			report.Permissions = append(report.Permissions, PermissionStatus{Name: p, Granted: granted[p]})
+		}
+	}
+
+	report.Log(log)
+	return report, nil
+}
@@ -0,0 +1,131 @@
+package gcpUtils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// איך SetProject בוחר פרויקט
+const (
+	// ProjectScopeEnv - רק משתני סביבה של התהליך הנוכחי (ברירת מחדל)
+	ProjectScopeEnv = "env"
+	// ProjectScopeConfiguration - gcloud configuration ייעודית דרך CLOUDSDK_ACTIVE_CONFIG_NAME
+	ProjectScopeConfiguration = "configuration"
+	// ProjectScopeGlobal - ההתנהגות הישנה: gcloud config set project (משנה את המצב הגלובלי!)
+	ProjectScopeGlobal = "global"
+)
+
+// מה צריך לשחזר ביציאה - רק ה-gcloud config הגלובלי. משתני הסביבה שייכים לתהליך הזה בלבד
+// ונעלמים איתו, לכן אין מה לשחזר בהם
+var (
+	restoreMu       sync.Mutex
+	previousProject *string // רק במצב global
+)
+
+// gcloud מריץ gcloud ומחזיר רק את ה-stdout (משתנה כדי שהבדיקות יוכלו להחליף אותו)
+var gcloud = func(log *zerolog.Logger, args ...string) (string, error) {
+	return RunCommandOutput(log, "gcloud", args...)
+}
+
+// configValue קורא ערך מה-gcloud config הפעיל. "" כשהערך לא מוגדר ("(unset)")
+func configValue(log *zerolog.Logger, property string) (string, error) {
+	out, err := gcloud(log, "config", "get-value", property, "--format=value(core."+property+")")
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(out)
+	if value == "(unset)" {
+		value = ""
+	}
+	return value, nil
+}
+
+// savePreviousProject שומר את הפרויקט הגלובלי הנוכחי לשחזור ביציאה (רק בפעם הראשונה)
+func savePreviousProject(log *zerolog.Logger) {
+	old, err := configValue(log, "project")
+	if err != nil {
+		log.Warn().Err(err).Msg("⚠️ Failed to read the current gcloud project - it will not be restored")
+		return
+	}
+	restoreMu.Lock()
+	defer restoreMu.Unlock()
+	if previousProject == nil {
+		previousProject = &old
+	}
+}
+
+// setScopedEnv מגדיר משתנה סביבה לתהליך הנוכחי (ולכלים שהוא מריץ) בלבד
+func setScopedEnv(key, value string) {
+	os.Setenv(key, value)
+}
+
+// setProjectEnv - הפרויקט והמכסה עבור gcloud, Terraform וספריות Go, רק בתהליך הזה
+func setProjectEnv(projectID string) {
+	setScopedEnv("CLOUDSDK_CORE_PROJECT", projectID)
+	setScopedEnv("CLOUDSDK_BILLING_QUOTA_PROJECT", projectID)
+	setScopedEnv("GOOGLE_CLOUD_PROJECT", projectID)
+	setScopedEnv("GOOGLE_CLOUD_QUOTA_PROJECT", projectID)
+	setScopedEnv("GOOGLE_TERRAFORM_QUOTA_PROJECT", projectID)
+}
+
+// configurationName - שם ה-gcloud configuration הייעודית לפרויקט
+func configurationName(projectID string) string {
+	return "devops-" + strings.ToLower(projectID)
+}
+
+// setProjectConfiguration יוצר (אם צריך) gcloud configuration ייעודית ומפעיל אותה רק לתהליך הזה
+func setProjectConfiguration(log *zerolog.Logger, projectID string) error {
+	name := configurationName(projectID)
+
+	// החשבון הפעיל כרגע - כדי שה-configuration החדשה תשתמש באותו משתמש
+	account, _ := configValue(log, "account")
+
+	if _, err := RunCommand(log, "gcloud", "config", "configurations", "describe", name); err != nil {
+		log.Info().Str("configuration", name).Msg("🆕 Creating dedicated gcloud configuration")
+		if _, err := RunCommand(log, "gcloud", "config", "configurations", "create", name, "--no-activate"); err != nil {
+			return fmt.Errorf("failed to create gcloud configuration %s: %w", name, err)
+		}
+	}
+
+	settings := [][2]string{
+		{"project", projectID},
+		{"billing/quota_project", projectID},
+	}
+	if account != "" {
+		settings = append(settings, [2]string{"account", account})
+	}
+	for _, kv := range settings {
+		if _, err := RunCommand(log, "gcloud", "config", "set", kv[0], kv[1], "--configuration="+name); err != nil {
+			return fmt.Errorf("failed to set %s in gcloud configuration %s: %w", kv[0], name, err)
+		}
+	}
+
+	setScopedEnv("CLOUDSDK_ACTIVE_CONFIG_NAME", name)
+	log.Info().Str("configuration", name).Msg("✅ Using dedicated gcloud configuration for this process")
+	return nil
+}
+
+// RestoreProject restores the previously active gcloud project changed by SetProject in the
+// global scope. Process environment variables need no restore. Call it on every exit path.
+func RestoreProject(log *zerolog.Logger) {
+	restoreMu.Lock()
+	defer restoreMu.Unlock()
+
+	if previousProject != nil {
+		log.Info().Str("project", *previousProject).Msg("↩️ Restoring previous global gcloud project")
+		var err error
+		if *previousProject == "" {
+			_, err = gcloud(log, "config", "unset", "project")
+		} else {
+			_, err = gcloud(log, "config", "set", "project", *previousProject)
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("⚠️ Failed to restore previous gcloud project")
+		}
+		previousProject = nil
+	}
+}
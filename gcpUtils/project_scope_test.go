@@ -0,0 +1,97 @@
+package gcpUtils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeGcloud מחליף את gcloud: מחזיר stdout לפי "get-value <property>" ורושם כל פקודה אחרת
+func fakeGcloud(t *testing.T, values map[string]string) *[][]string {
+	t.Helper()
+	var calls [][]string
+	original := gcloud
+	gcloud = func(log *zerolog.Logger, args ...string) (string, error) {
+		if len(args) > 2 && args[0] == "config" && args[1] == "get-value" {
+			return values[args[2]], nil
+		}
+		calls = append(calls, args)
+		return "", nil
+	}
+	t.Cleanup(func() {
+		gcloud = original
+		previousProject = nil
+	})
+	previousProject = nil
+	return &calls
+}
+
+func TestConfigValue(t *testing.T) {
+	log := zerolog.Nop()
+	tests := []struct {
+		stdout string
+		want   string
+	}{
+		{"my-project\n", "my-project"},
+		{"(unset)\n", ""},
+		{"", ""},
+		{"  \n", ""},
+	}
+	for _, tt := range tests {
+		fakeGcloud(t, map[string]string{"project": tt.stdout})
+		got, err := configValue(&log, "project")
+		if err != nil {
+			t.Fatalf("configValue() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("configValue() with stdout %q = %q, want %q", tt.stdout, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreProject(t *testing.T) {
+	log := zerolog.Nop()
+	tests := []struct {
+		name     string
+		previous string
+		want     []string
+	}{
+		{"previous project", "old-project\n", []string{"config", "set", "project", "old-project"}},
+		{"unset", "(unset)\n", []string{"config", "unset", "project"}},
+		{"empty", "", []string{"config", "unset", "project"}},
+	}
+	for _, tt := range tests {
+		calls := fakeGcloud(t, map[string]string{"project": tt.previous})
+
+		savePreviousProject(&log)
+		// רק הפרויקט הראשון נשמר - מעבר נוסף בין סביבות לא דורס אותו
+		previousNow := *previousProject
+		gcloud = func(log *zerolog.Logger, args ...string) (string, error) {
+			if args[1] == "get-value" {
+				return "env-project\n", nil
+			}
+			*calls = append(*calls, args)
+			return "", nil
+		}
+		savePreviousProject(&log)
+		if *previousProject != previousNow {
+			t.Errorf("%s: saved project changed to %q", tt.name, *previousProject)
+		}
+
+		RestoreProject(&log)
+		if len(*calls) != 1 || !reflect.DeepEqual((*calls)[0], tt.want) {
+			t.Errorf("%s: gcloud calls = %v, want [%s]", tt.name, *calls, strings.Join(tt.want, " "))
+		}
+		if previousProject != nil {
+			t.Errorf("%s: previous project still set after restore", tt.name)
+		}
+
+		// שחזור שני לא עושה כלום
+		RestoreProject(&log)
+		if len(*calls) != 1 {
+			t.Errorf("%s: second RestoreProject ran gcloud again: %v", tt.name, *calls)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package gcpUtils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// secretVersionName משלים הפניה ל-Secret Manager לשם גרסה מלא:
+// projects/P/secrets/S/versions/V כמו שהוא, projects/P/secrets/S -> latest,
+// S או S@V -> בפרויקט הנוכחי
+func secretVersionName(name string) string {
+	if strings.HasPrefix(name, "projects/") {
+		if strings.Contains(name, "/versions/") {
+			return name
+		}
+		return name + "/versions/latest"
+	}
+
+	secret, version, ok := strings.Cut(name, "@")
+	if !ok || version == "" {
+		version = "latest"
+	}
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/%s", CurrentConfig().ProjectID, secret, version)
+}
+
+// AccessSecret reads a Secret Manager secret version with the configured credentials.
+// name is a full version name, a secret name (latest version) or "secret@version" in the current project.
+func AccessSecret(ctx context.Context, name string) (string, error) {
+	resource := secretVersionName(name)
+
+	opts, err := ClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	svc, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+
+	resp, err := svc.Projects.Secrets.Versions.Access(resource).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", resource, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", resource, err)
+	}
+	return string(data), nil
+}
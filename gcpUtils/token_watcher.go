@@ -0,0 +1,101 @@
+package gcpUtils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	tokenCheckInterval = time.Minute
+	tokenWarnBefore    = 5 * time.Minute // מזהירים ב-log viewer כשנשאר פחות מזה
+)
+
+// TokenWatcher tracks the expiry of the shared GCP credentials (the ones currentCredentials
+// caches) during long runs and warns before they expire or when they can no longer be resolved.
+// It holds no token of its own and refreshes nothing: Terraform (ADC / GOOGLE_IMPERSONATE_SERVICE_ACCOUNT)
+// and Docker (the gcloud credential helper) mint their own tokens, so there is nothing to re-sync.
+type TokenWatcher struct {
+	log *zerolog.Logger
+
+	mu     sync.Mutex
+	expiry time.Time
+}
+
+var (
+	watcherOnce sync.Once
+	watcher     *TokenWatcher
+)
+
+// StartTokenWatcher starts the background watcher once per process and returns it.
+func StartTokenWatcher(ctx context.Context, log *zerolog.Logger) *TokenWatcher {
+	watcherOnce.Do(func() {
+		watcher = &TokenWatcher{log: log}
+		watcher.check()
+		go watcher.run(ctx)
+		log.Info().Dur("interval", tokenCheckInterval).Msg("⏱️ GCP token expiry watcher started")
+	})
+	return watcher
+}
+
+// Expiry returns the expiry of the shared credentials' token (zero if unknown).
+func (w *TokenWatcher) Expiry() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.expiry
+}
+
+func (w *TokenWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check קורא את ה-credentials המשותפים (ה-cache מתחדש בעצמו סמוך לפקיעה) ומזהיר
+// כשה-token עומד לפוג או כשאי אפשר יותר לקבל credentials
+func (w *TokenWatcher) check() {
+	info, err := currentCredentials(w.log)
+	if err != nil {
+		expiry := w.Expiry()
+		remaining := time.Until(expiry)
+		switch {
+		case expiry.IsZero():
+			w.log.Warn().Err(err).Msg("⚠️ Failed to resolve GCP credentials")
+		case remaining <= 0:
+			w.log.Error().Err(err).Time("expired_at", expiry).Msg("❌ GCP access token has expired and could not be renewed - re-authenticate")
+		case remaining <= tokenWarnBefore:
+			w.log.Warn().Err(err).Dur("expires_in", remaining.Round(time.Second)).Msg("⚠️ GCP access token is about to expire and could not be renewed")
+		default:
+			w.log.Warn().Err(err).Msg("⚠️ Failed to resolve GCP credentials")
+		}
+		return
+	}
+
+	w.mu.Lock()
+	renewed := !w.expiry.IsZero() && !info.Expiry.Equal(w.expiry)
+	w.expiry = info.Expiry
+	w.mu.Unlock()
+
+	if info.Expiry.IsZero() {
+		return
+	}
+	remaining := time.Until(info.Expiry)
+	switch {
+	case renewed:
+		w.log.Info().Time("expiry", info.Expiry).Msg("🔄 GCP access token renewed")
+	case remaining <= tokenWarnBefore:
+		w.log.Warn().Dur("expires_in", remaining.Round(time.Second)).Msg("⚠️ GCP access token is about to expire")
+	default:
+		w.log.Debug().Dur("expires_in", remaining.Round(time.Second)).Msg("GCP token still valid")
+	}
+}
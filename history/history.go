@@ -0,0 +1,101 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// קובץ היסטוריית ההרצות - שורת JSON לכל הרצה, לצד app.log
+var historyFilePath = filepath.Join(".", "run_history.jsonl")
+var historyMu sync.Mutex
+
+// Entry מתאר הרצה אחת של שלב בתהליך (promotion, apply, drift וכו')
+type Entry struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind"`   // למשל "docker-promote"
+	Status   string            `json:"status"` // StatusSuccess / StatusFailed
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Details  map[string]string `json:"details,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// NewEntry יוצר רשומה חדשה עם מזהה וזמן התחלה
+func NewEntry(kind string) Entry {
+	now := time.Now()
+	return Entry{
+		ID:      fmt.Sprintf("%s-%d", kind, now.UnixNano()),
+		Kind:    kind,
+		Started: now,
+		Details: map[string]string{},
+	}
+}
+
+// Finish מסמן את סיום ההרצה לפי השגיאה (nil = הצלחה)
+func (e *Entry) Finish(err error) {
+	e.Finished = time.Now()
+	if err != nil {
+		e.Status = StatusFailed
+		e.Error = err.Error()
+		return
+	}
+	e.Status = StatusSuccess
+}
+
+// Record מוסיף רשומה לסוף קובץ ההיסטוריה
+func Record(entry Entry) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := os.OpenFile(historyFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open run history: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// List מחזיר את כל הרשומות (מהישנה לחדשה). kind ריק מחזיר את כל הסוגים.
+func List(kind string) ([]Entry, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := os.Open(historyFilePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // שורה פגומה לא תפיל את כל ההיסטוריה
+		}
+		if kind == "" || e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
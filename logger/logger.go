@@ -18,18 +18,24 @@ type multiWriterLog struct {
 }
 
 func (w *multiWriterLog) Write(p []byte) (n int, err error) {
+	// 0. הסתרת ערכים סודיים (משתני Terraform וכו') לפני שהשורה נכתבת לקובץ או ל-WebSocket
+	length := len(p)
+	p = redact(p)
+
 	// 1. הפיכת ה-JSON הדחוס ל-JSON מעוצב (Pretty Print)
 	var jsonObj interface{}
 	// מפענחים את השורה שקיבלנו מ-zerolog
 	if err := json.Unmarshal(p, &jsonObj); err != nil {
 		// אם זה לא JSON תקני, נכתוב אותו כפי שהוא
-		return w.fileWriter.Write(p)
+		_, err := w.fileWriter.Write(p)
+		return length, err
 	}
 
 	// יוצרים JSON חדש עם רווחים (4 רווחים להזחה)
 	prettyJSON, err := json.MarshalIndent(jsonObj, "", "    ")
 	if err != nil {
-		return w.fileWriter.Write(p)
+		_, err := w.fileWriter.Write(p)
+		return length, err
 	}
 
 	// מוסיפים ירידת שורה כפולה בין לוג ללוג כדי שיהיה קל להבדיל ביניהם
@@ -50,7 +56,7 @@ func (w *multiWriterLog) Write(p []byte) (n int, err error) {
 	}
 
 	// מחזירים את האורך המקורי של p כדי ש-zerolog לא יחשוב שהייתה שגיאה
-	return len(p), nil
+	return length, nil
 }
 
 // InitLogger מאתחל את מערכת הלוגינג
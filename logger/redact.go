@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// redactedValue - מה שנכתב ללוג במקום ערך סודי
+const redactedValue = "***REDACTED***"
+
+// minSecretLength - ערכים קצרים מזה לא מוסתרים (אחרת כל "true" או "dev" בלוג היה נמחק)
+const minSecretLength = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string]bool{}
+	ordered   [][]byte // הארוכים קודם - כדי שסוד שמכיל סוד אחר יוסתר במלואו
+)
+
+// RegisterSecret marks value as secret: from now on it is replaced with ***REDACTED***
+// in every log line (file and WebSocket stream).
+func RegisterSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets[value] = true
+
+	// בתוך שורת JSON הערך מופיע מקודד (גרשיים, ירידות שורה וכו')
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err == nil {
+		escaped := string(bytes.TrimSuffix(bytes.TrimSpace(buf.Bytes()), []byte(`"`))[1:])
+		if escaped != value && len(escaped) >= minSecretLength {
+			secrets[escaped] = true
+		}
+	}
+
+	ordered = ordered[:0]
+	for s := range secrets {
+		ordered = append(ordered, []byte(s))
+	}
+	sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+}
+
+// redact מחליף את כל הערכים הסודיים שנרשמו בשורת הלוג
+func redact(p []byte) []byte {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, secret := range ordered {
+		if bytes.Contains(p, secret) {
+			p = bytes.ReplaceAll(p, secret, []byte(redactedValue))
+		}
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+// resetSecrets - מנקה את הסודות שנרשמו בין הבדיקות
+func resetSecrets(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		secretsMu.Lock()
+		defer secretsMu.Unlock()
+		secrets = map[string]bool{}
+		ordered = nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestRedactPlainValue(t *testing.T) {
+	resetSecrets(t)
+	RegisterSecret("s3cr3t-token")
+
+	got := string(redact([]byte(`{"level":"info","message":"token s3cr3t-token used"}`)))
+	want := `{"level":"info","message":"token ` + redactedValue + ` used"}`
+	if got != want {
+		t.Errorf("redact() = %s, want %s", got, want)
+	}
+}
+
+func TestRedactJSONEscapedValue(t *testing.T) {
+	resetSecrets(t)
+	secret := "pa\"ss\nword<>"
+	RegisterSecret(secret)
+
+	// zerolog כותב את הערך מקודד: גרשיים ו-\n עם backslash, בלי קידוד HTML
+	line := `{"message":"value pa\"ss\nword<> here"}`
+	got := string(redact([]byte(line)))
+	if strings.Contains(got, "pa\\\"ss") || strings.Contains(got, "word<>") {
+		t.Errorf("JSON-escaped secret not redacted: %s", got)
+	}
+	if want := `{"message":"value ` + redactedValue + ` here"}`; got != want {
+		t.Errorf("redact() = %s, want %s", got, want)
+	}
+
+	// גם הערך הגולמי (למשל בפלט של פקודה) מוסתר
+	if got := string(redact([]byte("raw " + secret))); got != "raw "+redactedValue {
+		t.Errorf("raw secret not redacted: %q", got)
+	}
+}
+
+func TestRegisterSecretSkipsShortValues(t *testing.T) {
+	resetSecrets(t)
+	RegisterSecret("dev")
+	RegisterSecret("")
+
+	line := `{"env":"dev"}`
+	if got := string(redact([]byte(line))); got != line {
+		t.Errorf("short value redacted: %s", got)
+	}
+}
+
+func TestRedactLongestFirst(t *testing.T) {
+	resetSecrets(t)
+	RegisterSecret("abcd")
+	RegisterSecret("abcd-efgh-ijkl")
+
+	got := string(redact([]byte("key=abcd-efgh-ijkl other=abcd")))
+	want := "key=" + redactedValue + " other=" + redactedValue
+	if got != want {
+		t.Errorf("redact() = %s, want %s", got, want)
+	}
+}
+
+func TestRedactWithoutSecrets(t *testing.T) {
+	resetSecrets(t)
+	line := `{"message":"nothing to hide"}`
+	if got := string(redact([]byte(line))); got != line {
+		t.Errorf("redact() changed a line without secrets: %s", got)
+	}
+}
@@ -1,6 +1,15 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
 	"DevOps/logger" 
 	"DevOps/dockerUtils"
 	"DevOps/gcpUtils"
@@ -30,51 +39,512 @@ var (
 		ImageName:       ImageName,
 		Tag:             "latest",
 	}
+)
 
-	gcpConfig = dockerUtils.PushConfig{
-		Registry:  dockerUtils.RegistryGCP,
-		ImageName: ImageName,
-		Tag:       "latest",
-		ProjectID: ProjectID,
-		Region:    Region,
-		RepoName:  RepoName,
+// APIs והרשאות שהתהליך צריך בפרויקט (נבדקים ב-preflight)
+var (
+	RequiredServices = []string{
+		"artifactregistry",
+		"storage",
+		"cloudresourcemanager",
 	}
+	RequiredPermissions = []string{
+		"storage.buckets.get",
+		"storage.buckets.create",
+		"storage.objects.create",
+		"artifactregistry.repositories.get",
+		"artifactregistry.repositories.create",
+	}
+)
+
+// דגלי שורת הפקודה - בוחרים איזו פקודה להריץ
+var (
+	command = flag.String("cmd", "workflow", "Command to run: workflow | registry-cleanup | promote | compose | repo-drift | state-snapshots | state-restore | drift | providers-mirror")
+	envList = flag.String("env", "dev", "Environment(s) to run, comma separated and run in order (e.g. dev,staging)")
+	destroy = flag.Bool("destroy", false, "Run terraform destroy instead of apply")
+
+	// destroy בטוח
+	confirmDestroy = flag.String("confirm-destroy", "", "Project ID that confirms -destroy without the interactive prompt")
+	protectedEnvs  = flag.String("protected-envs", "prod", "Comma separated environments that refuse -destroy entirely")
+
+	// workspaces
+	workspaceFlag           = flag.String("workspace", "", "Terraform workspace to select (created if missing); overrides the environment's workspace")
+	protectedWorkspacesFlag = flag.String("protected-workspaces", "prod", "Comma separated Terraform workspaces that refuse -destroy entirely")
+
+	// terraform
+	estimateCost = flag.Bool("estimate-cost", false, "Plan first and log the estimated monthly cost delta before applying")
+	pricingFile  = flag.String("pricing-file", "", "Pricing table JSON for -estimate-cost (default: bundled table)")
+	stateKMSKey  = flag.String("state-kms-key", "", "Cloud KMS key (CMEK) for newly created Terraform state buckets")
+	migrateState = flag.Bool("migrate-state", false, "Confirm copying existing state when the Terraform backend configuration changed")
+	reconfigure  = flag.Bool("reconfigure", false, "When the Terraform backend configuration changed, start from the new backend without copying state")
+
+	// Artifact Registry repository (בנוסף להגדרות Repo של הסביבה)
+	repoKMSKey        = flag.String("repo-kms-key", "", "Cloud KMS key (CMEK) for newly created Artifact Registry repositories; overrides the environment's")
+	repoCleanupPolicy = flag.String("repo-cleanup-policy", "", "Cleanup policies JSON file (gcloud format) for the Artifact Registry repository; overrides the environment's")
+	repoCleanupDryRun = flag.Bool("repo-cleanup-dry-run", false, "Create the repository with its cleanup policies in dry-run mode (with -repo-cleanup-policy)")
+
+	// providers
+	upgradeProviders = flag.Bool("upgrade", false, "Run terraform init -upgrade (update providers and the lock file)")
+	pluginCacheDir   = flag.String("plugin-cache-dir", "", "Shared Terraform plugin cache directory (default: user cache dir, \"-\" = none)")
+	offline          = flag.Bool("offline", false, "Install providers only from the local mirror (air-gapped init)")
+	providerMirror   = flag.String("provider-mirror", tfUtils.DefaultProviderMirrorDir, "Local filesystem provider mirror for -offline (filled by -cmd providers-mirror)")
+
+	// GCP auth
+	gcpKeyFile     = flag.String("gcp-key-file", "", "Service account key / external account config file (default: ADC)")
+	gcloudFallback = flag.Bool("gcloud-fallback", false, "Fall back to the gcloud CLI when Go client credentials are unavailable")
+	impersonate    = flag.String("impersonate", "", "Deploy service account to impersonate for Terraform, Artifact Registry and GCS")
+	projectScope   = flag.String("project-scope", gcpUtils.ProjectScopeEnv, "How to select the GCP project: env | configuration | global (changes gcloud config for every terminal)")
+	preflight      = flag.Bool("preflight", true, "Verify required APIs and IAM permissions before running")
+	enableAPIs     = flag.Bool("enable-apis", false, "Enable required APIs that are disabled (preflight)")
+	headless       = flag.Bool("headless", os.Getenv("CI") != "", "Never prompt for login; authenticate from a key file or GOOGLE_APPLICATION_CREDENTIALS")
+
+	// state snapshots
+	snapshotDir      = flag.String("snapshot-dir", tfUtils.DefaultSnapshotDir, "Local directory for Terraform state snapshots (\"-\" = none)")
+	snapshotInBucket = flag.Bool("snapshot-in-bucket", false, "Also keep state snapshots in the state bucket")
+	snapshotID       = flag.String("snapshot", "", "Snapshot ID to restore (state-restore)")
+	stackName        = flag.String("stack", "", "Stack to restore in environments with multiple stacks (state-restore)")
+	forceRestore     = flag.Bool("force-restore", false, "Restore even if the snapshot lineage differs from the current state or the snapshot is older than it (discards later changes)")
+
+	// web server / API
+	listenAddr        = flag.String("listen", "127.0.0.1:9090", "Address of the web dashboard and API (use 0.0.0.0:9090 to expose it)")
+	apiToken          = flag.String("api-token", os.Getenv("DEVOPS_API_TOKEN"), "Bearer token required by POST /api/terraform/run (empty = API runs disabled)")
+	apiAllowProtected = flag.Bool("api-allow-protected", false, "Allow API-triggered runs on environments listed in -protected-envs")
+
+	// targeting
+	refreshOnly = flag.Bool("refresh-only", false, "Only update the state to match real infrastructure (no changes)")
+	parallelism = flag.Int("parallelism", 0, "Limit concurrent Terraform operations (0 = Terraform default)")
+
+	// variables
+	varsViaEnv = flag.Bool("vars-via-env", false, "Pass -var values as TF_VAR_ environment variables instead of a temporary 0600 tfvars file")
+
+	// drift
+	driftInterval = flag.Duration("drift-interval", 0, "Repeat the drift check at this interval (e.g. 1h); 0 = run once")
+
+	// registry-cleanup
+	dryRun       = flag.Bool("dry-run", true, "Only report what would be deleted")
+	keepLast     = flag.Int("keep-last", 10, "Number of most recent tagged versions to keep per image")
+	keepTags     = flag.String("keep-tags", "", "Regexp of tags that are always kept (e.g. ^v[0-9]+)")
+	untaggedDays = flag.Int("untagged-days", 7, "Delete untagged versions older than N days (0 = never)")
+
+	// promote
+	promoteTag   = flag.String("tag", "latest", "Source image tag to promote")
+	promoteToTag = flag.String("to-tag", "", "Destination tag (defaults to the source tag)")
+	promoteToEnv = flag.String("to-env", "", "Destination environment registry (default: Docker Hub)")
+
+	// compose
+	composeFile = flag.String("compose-file", "", "Compose file to build and push (compose); default: docker compose's standard names (compose.yaml, docker-compose.yml, ...)")
+	composePush = flag.String("compose-push", "", "Per-service push mapping: service=gcp|docker[:image], comma separated (compose); unmapped services are built only")
+)
+
+// stringList - דגל שאפשר להעביר כמה פעמים (-target a -target b), כמו ב-Terraform
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
 
-	opts = tfUtils.TerraformOptions{
-        ProjectID:       ProjectID,
-        TerraformDir:    TerraformDir,
-        VarFile:         VarFile,
-        BackendVarsFile: BackendVarsFile,
-        Destroy:         false, // שנה ל-true אם אתה רוצה למחוק
-    }
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+var (
+	targets      stringList
+	replaceAddrs stringList
+	varFlags     stringList
 )
 
+func init() {
+	flag.Var(&targets, "target", "Resource address to target (repeatable) - the run is recorded as partial")
+	flag.Var(&replaceAddrs, "replace", "Resource address to force replacement of (repeatable)")
+	flag.Var(&varFlags, "var", "Terraform variable key=value (repeatable), always passed as a string - put lists / maps in the stack var file. value may be env:NAME, file:PATH or secret:NAME[@VERSION] (Secret Manager)")
+}
+
+// varsFromFlags - המשתנים מ--var. הפניות (env: / file: / secret:) נקראות רק בזמן ההרצה
+func varsFromFlags() (map[string]string, error) {
+	vars := map[string]string{}
+	for _, kv := range varFlags {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid -var %q, expected key=value", key)
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars, nil
+}
+
+// targetingFromFlags - ה-targeting של ההרצה מהדגלים
+func targetingFromFlags() tfUtils.Targeting {
+	return tfUtils.Targeting{
+		Targets:     targets,
+		Replace:     replaceAddrs,
+		RefreshOnly: *refreshOnly,
+		Parallelism: *parallelism,
+	}
+}
+
+// workflowMu - הרצה אחת של Terraform בכל רגע (CLI, drift או בקשה מה-API)
+var workflowMu sync.Mutex
+
+// runRegistryCleanup מפעיל את מדיניות השמירה על ה-Repository של GCP בכל סביבה
+func runRegistryCleanup(envs []Environment) {
+	policy := dockerUtils.RetentionPolicy{
+		KeepLast:          *keepLast,
+		KeepTagPattern:    *keepTags,
+		UntaggedOlderThan: time.Duration(*untaggedDays) * 24 * time.Hour,
+		DryRun:            *dryRun,
+	}
+
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+		_, err := dockerUtils.CleanupRegistry(&log, env.PushConfig(), policy)
+		return err
+	})
+}
+
+
+// runPromote מקדם image קיים לפי digest, בלי build מחדש: מה-registry של הסביבה
+// ל-registry של סביבת היעד (-to-env), או ל-Docker Hub
+func runPromote(envs []Environment) {
+	var dst dockerUtils.PushConfig
+	if *promoteToEnv != "" {
+		target, err := selectEnvironments(*promoteToEnv)
+		if err != nil {
+			log.Error().Err(err).Msg("❌ Invalid promotion target")
+			return
+		}
+		dst = target[0].PushConfig()
+	} else {
+		dst = DockerConfig
+	}
+	dst.Tag = *promoteTag
+	if *promoteToTag != "" {
+		dst.Tag = *promoteToTag
+	}
+
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+
+		src := env.PushConfig()
+		src.Tag = *promoteTag
+
+		_, err := dockerUtils.PromoteImage(&log, src, dst)
+		return err
+	})
+}
+
+// runCompose בונה את כל השירותים בקובץ ה-compose ודוחף כל שירות ל-registry שלו (-compose-push)
+func runCompose(envs []Environment) {
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
 
+		targets, err := dockerUtils.ComposeTargets(*composePush, map[dockerUtils.RegistryType]dockerUtils.PushConfig{
+			dockerUtils.RegistryDocker: DockerConfig,
+			dockerUtils.RegistryGCP:    env.PushConfig(),
+		})
+		if err != nil {
+			return err
+		}
 
+		_, err = dockerUtils.BuildPushCompose(&log, *composeFile, targets)
+		return err
+	})
+}
+
+// runRepoDrift משווה את ההגדרות של ה-Artifact Registry repository בכל סביבה להגדרות הרצויות
+func runRepoDrift(envs []Environment) {
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+		_, err := dockerUtils.CheckGCPRepoDrift(&log, env.PushConfig())
+		return err
+	})
+}
+
+// snapshotSettings - הגדרות ה-snapshots של ה-state מהדגלים
+func snapshotSettings() tfUtils.SnapshotSettings {
+	return tfUtils.SnapshotSettings{
+		LocalDir: *snapshotDir,
+		InBucket: *snapshotInBucket,
+	}
+}
+
+// providerSettings - הגדרות התקנת ה-providers מהדגלים
+func providerSettings() tfUtils.ProviderSettings {
+	return tfUtils.ProviderSettings{
+		CacheDir:  *pluginCacheDir,
+		Upgrade:   *upgradeProviders,
+		Offline:   *offline,
+		MirrorDir: *providerMirror,
+	}
+}
+
+// runProvidersMirror מוריד את ה-providers של כל ה-stacks ל-mirror המקומי (להרצה offline)
+func runProvidersMirror(envs []Environment) {
+	runEnvironments(envs, func(env Environment) error {
+		stacks, err := env.stackOptions(false)
+		if err != nil {
+			return err
+		}
+		for _, opts := range stacks {
+			if err := tfUtils.MirrorProviders(&log, opts, *providerMirror); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runStateSnapshots מציג את ה-snapshots של ה-state בכל סביבה
+func runStateSnapshots(envs []Environment) {
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+
+		base := env.TerraformOptions(false)
+		base.Snapshots = snapshotSettings()
+		base.Providers = providerSettings()
+		stacks, err := env.resolvedStackOptions(base)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, opts := range stacks {
+			snaps, err := tfUtils.ListStateSnapshots(&log, opts)
+			if err != nil {
+				return err
+			}
+			total += len(snaps)
+
+			for _, s := range snaps {
+				log.Info().
+					Str("stack", opts.TerraformDir).
+					Str("snapshot", s.ID).
+					Int64("serial", s.Serial).
+					Str("lineage", s.Lineage).
+					Time("created", s.Created).
+					Str("reason", s.Reason).
+					Str("location", s.Location).
+					Msg("📸 State snapshot")
+			}
+		}
+		log.Info().Str("environment", env.Name).Int("snapshots", total).Msg("📚 State snapshots listed")
+		return nil
+	})
+}
+
+// runStateRestore משחזר snapshot נבחר (-snapshot) ל-state של הסביבה
+func runStateRestore(envs []Environment) {
+	if *snapshotID == "" {
+		log.Error().Msg("❌ -snapshot is required for state-restore")
+		return
+	}
+
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+
+		opts, err := env.stack(*stackName)
+		if err != nil {
+			return err
+		}
+		opts.Snapshots = snapshotSettings()
+		opts.Providers = providerSettings()
+		return tfUtils.RestoreStateSnapshot(&log, opts, *snapshotID, *forceRestore)
+	})
+}
+
+// runDrift בודק drift בכל סביבה - פעם אחת, או כל -drift-interval
+func runDrift(envs []Environment) {
+	for {
+		workflowMu.Lock()
+		runEnvironments(envs, func(env Environment) error {
+			if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+				return err
+			}
+			vars, err := varsFromFlags()
+			if err != nil {
+				return err
+			}
+			// ה-vars מהדגלים הם הבסיס; כל stack מוסיף עליהם את ה-Inputs שלו
+			base := env.TerraformOptions(false)
+			base.Providers = providerSettings()
+			base.Vars = vars
+			base.VarsViaEnv = *varsViaEnv
+			stacks, err := env.resolvedStackOptions(base)
+			if err != nil {
+				return err
+			}
+			for _, opts := range stacks {
+				if _, err := tfUtils.DetectDrift(&log, opts); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		workflowMu.Unlock()
+
+		if *driftInterval <= 0 {
+			return
+		}
+		log.Info().Dur("interval", *driftInterval).Msg("⏰ Next drift check scheduled")
+		time.Sleep(*driftInterval)
+	}
+}
+
+// restoreOnFatal - log.Fatal יוצא מיד (os.Exit) בלי defer ובלי ה-signal handler,
+// לכן הפרויקט הגלובלי משוחזר מתוך hook של ה-logger, רגע לפני היציאה
+type restoreOnFatal struct{}
+
+func (restoreOnFatal) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level == zerolog.FatalLevel {
+		gcpUtils.RestoreProject(&log)
+	}
+}
 
 func main() {
+	flag.Parse()
 
-	log = logger.InitLogger(true)
+	log = logger.InitLogger(true).Hook(restoreOnFatal{})
 	go startWebServer()
-	
-	gcpUtils.RunGCPCheck(&log, ProjectID)
-
-	// dockerUtils.FullBuildTagPushWithRegistry(
-	// 	&log,
-	// 	".",
-	// 	"myapp:latest",
-	// 	DockerConfig,
-	// ) 
-
-	// dockerUtils.FullBuildTagPushWithRegistry(
-	// 	&log,
-	// 	".",
-	// 	"wiki:latest",
-	// 	gcpConfig,
-	// )
-
-	
-    tfUtils.RunTerraformWorkflow(&log, opts)
-	
+
+	gcpCfg := gcpUtils.Config{
+		ProjectID:      ProjectID,
+		KeyFile:        *gcpKeyFile,
+		GcloudFallback: *gcloudFallback,
+		Headless:       *headless,
+		ProjectScope:   *projectScope,
+
+		ImpersonateServiceAccount: *impersonate,
+	}
+	if *preflight {
+		gcpCfg.RequiredServices = RequiredServices
+		gcpCfg.RequiredPermissions = RequiredPermissions
+		gcpCfg.EnableMissingServices = *enableAPIs
+	}
+	gcpUtils.Configure(gcpCfg)
+
+	// שחזור ה-gcloud config הגלובלי ביציאה (Ctrl+C / SIGTERM; log.Fatal - דרך restoreOnFatal)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		gcpUtils.RestoreProject(&log)
+		os.Exit(0)
+	}()
+
+	envs, err := selectEnvironments(*envList)
+	if err != nil {
+		log.Fatal().Err(err).Msg("❌ Invalid -env")
+	}
+	if err := validateStacks(envs); err != nil {
+		log.Fatal().Err(err).Msg("❌ Invalid stack configuration")
+	}
+
+	switch *command {
+	case "registry-cleanup":
+		runRegistryCleanup(envs)
+	case "promote":
+		runPromote(envs)
+	case "compose":
+		runCompose(envs)
+	case "repo-drift":
+		runRepoDrift(envs)
+	case "state-snapshots":
+		runStateSnapshots(envs)
+	case "state-restore":
+		runStateRestore(envs)
+	case "drift":
+		runDrift(envs)
+	case "providers-mirror":
+		runProvidersMirror(envs)
+	default:
+		workflowMu.Lock()
+		runWorkflow(envs, *destroy, targetingFromFlags(), true)
+		workflowMu.Unlock()
+	}
+
 	select {}
 }
+
+// runWorkflow - תהליך ברירת המחדל (GCP + Docker + Terraform), סביבה אחרי סביבה.
+// interactive=false (API) - אישורים שדורשים הקלדה נכשלים מיד במקום לחכות ל-stdin
+func runWorkflow(envs []Environment, destroy bool, targeting tfUtils.Targeting, interactive bool) {
+	runEnvironments(envs, func(env Environment) error {
+		if err := gcpUtils.CheckGCP(&log, env.ProjectID); err != nil {
+			return err
+		}
+
+		vars, err := varsFromFlags()
+		if err != nil {
+			return err
+		}
+
+		opts := env.TerraformOptions(destroy)
+		opts.Vars = vars
+		opts.VarsViaEnv = *varsViaEnv
+		opts.Targeting = targeting
+		opts.EstimateCost = *estimateCost
+		opts.PricingFile = *pricingFile
+		opts.Protected = isProtected(env.Name)
+		opts.ConfirmProjectID = *confirmDestroy
+		opts.NonInteractive = !interactive
+		opts.Snapshots = snapshotSettings()
+		opts.MigrateState = *migrateState
+		opts.Reconfigure = *reconfigure
+		opts.Providers = providerSettings()
+		opts.StateBucket = tfUtils.StateBucketSettings{
+			KMSKey: *stateKMSKey,
+			Labels: map[string]string{"environment": env.Name},
+		}
+		var outputs tfUtils.Outputs
+		if len(env.Stacks) > 0 {
+			byStack, err := tfUtils.ExecuteStacksWorkflow(&log, opts, env.Stacks)
+			if err != nil {
+				return err
+			}
+			for _, s := range env.Stacks {
+				outputs = tfUtils.MergeOutputs(outputs, byStack[s.Name])
+			}
+		} else {
+			var err error
+			if outputs, err = tfUtils.ExecuteTerraformWorkflow(&log, opts); err != nil {
+				return err
+			}
+		}
+		if destroy || targeting.Partial() {
+			return nil
+		}
+
+		// השלבים הבאים משתמשים במה ש-Terraform יצר
+		pushCfg := env.PushConfigFromOutputs(outputs)
+		log.Info().Str("environment", env.Name).Str("repository", pushCfg.RepoName).Msg("📦 Artifact Registry repository for this environment")
+
+		// dockerUtils.FullBuildTagPushWithRegistry(
+		// 	&log,
+		// 	".",
+		// 	"myapp:latest",
+		// 	DockerConfig,
+		// )
+
+		// dockerUtils.FullBuildTagPushWithRegistry(
+		// 	&log,
+		// 	".",
+		// 	"wiki:latest",
+		// 	pushCfg,
+		// )
+
+		if url, ok := outputs.String(OutputServiceURL); ok {
+			return dockerUtils.CheckURL(&log, url, 0, 2*time.Minute)
+		}
+		return nil
+	})
+}
@@ -2,7 +2,11 @@ package tfUtils
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -31,6 +35,99 @@ func RunCommand(log *zerolog.Logger,name string, workingDir string, args ...stri
 
 
 func RunTerraform(log *zerolog.Logger, workingDir string, args ...string) (string, error) {
+	return RunTerraformWithEnv(log, workingDir, nil, args...)
+}
+
+// RunTerraformWithEnv מריץ terraform עם משתני סביבה נוספים (למשל TF_PLUGIN_CACHE_DIR)
+func RunTerraformWithEnv(log *zerolog.Logger, workingDir string, env []string, args ...string) (string, error) {
+	args = append(args, "-no-color")
+	cmd := exec.Command("terraform", args...)
+	cmd.Dir = workingDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	log.Debug().Strs("args", args).Strs("env", envNames(env)).Msg("⚙️ Executing command: terraform")
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("output", out.String()).
+			Str("command", "terraform").
+			Msg("❌ Command execution failed")
+	}
+
+	return out.String(), err
+}
+
+// RunTerraformOutput מריץ terraform ומחזיר רק את ה-stdout (למשל show -json), בלי לערבב אזהרות מ-stderr
+func RunTerraformOutput(log *zerolog.Logger, workingDir string, args ...string) (string, error) {
+	args = append(args, "-no-color")
+	cmd := exec.Command("terraform", args...)
+	cmd.Dir = workingDir
+	log.Debug().Strs("args", args).Msg("⚙️ Executing command: terraform")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("output", stderr.String()).
+			Str("command", "terraform").
+			Msg("❌ Command execution failed")
+		return stdout.String(), fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// RunTerraformExitCode מריץ terraform ומחזיר גם את קוד היציאה. קודים שמופיעים ב-okCodes
+// (למשל 2 של -detailed-exitcode = "יש שינויים") לא נחשבים כשלון
+func RunTerraformExitCode(log *zerolog.Logger, workingDir string, okCodes []int, args ...string) (string, int, error) {
+	return RunTerraformExitCodeWithEnv(log, workingDir, nil, okCodes, args...)
+}
+
+// RunTerraformExitCodeWithEnv - כמו RunTerraformExitCode, עם משתני סביבה נוספים
+func RunTerraformExitCodeWithEnv(log *zerolog.Logger, workingDir string, env []string, okCodes []int, args ...string) (string, int, error) {
 	args = append(args, "-no-color")
-	return RunCommand(log, "terraform", workingDir, args...)
+	cmd := exec.Command("terraform", args...)
+	cmd.Dir = workingDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	log.Debug().Strs("args", args).Strs("env", envNames(env)).Msg("⚙️ Executing command: terraform")
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	code := cmd.ProcessState.ExitCode()
+	if err != nil && !slices.Contains(okCodes, code) {
+		log.Error().
+			Err(err).
+			Str("output", out.String()).
+			Str("command", "terraform").
+			Msg("❌ Command execution failed")
+		return out.String(), code, err
+	}
+
+	return out.String(), code, nil
+}
+
+// envNames - רק שמות המשתנים ללוג (הערכים יכולים להיות סודיים, למשל TF_VAR_)
+func envNames(env []string) []string {
+	names := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		names = append(names, name)
+	}
+	return names
 }
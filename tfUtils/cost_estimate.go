@@ -0,0 +1,317 @@
+package tfUtils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// טבלת המחירים המובנית. אפשר לעדכן אותה כאן, או להעביר קובץ אחר דרך TerraformOptions.PricingFile
+//
+//go:embed pricing.json
+var defaultPricing []byte
+
+// PricingTable is the local price list used by EstimateCost (list prices, no discounts).
+type PricingTable struct {
+	Currency                string             `json:"currency"`
+	Updated                 string             `json:"updated"`
+	HoursPerMonth           float64            `json:"hours_per_month"`
+	ComputeHourly           map[string]float64 `json:"compute_hourly"`
+	CloudRun                CloudRunPricing    `json:"cloud_run"`
+	StorageGBMonth          map[string]float64 `json:"storage_gb_month"`
+	ArtifactRegistryGBMonth float64            `json:"artifact_registry_gb_month"`
+	AssumedBucketGB         float64            `json:"assumed_bucket_gb"`
+	AssumedRegistryGB       float64            `json:"assumed_registry_gb"`
+}
+
+// CloudRunPricing - מחירי Cloud Run לשנייה. מתומחרים רק מופעים מינימליים (שרצים תמיד)
+type CloudRunPricing struct {
+	VCPUSecond       float64 `json:"vcpu_second"`
+	GiBSecond        float64 `json:"gib_second"`
+	DefaultCPU       float64 `json:"default_cpu"`
+	DefaultMemoryGiB float64 `json:"default_memory_gib"`
+}
+
+// LoadPricing טוען את טבלת המחירים מקובץ, או את הטבלה המובנית אם path ריק
+func LoadPricing(path string) (*PricingTable, error) {
+	data := defaultPricing
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read pricing file: %w", err)
+		}
+	}
+
+	var table PricingTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing table: %w", err)
+	}
+	if table.HoursPerMonth == 0 {
+		table.HoursPerMonth = 730
+	}
+	return &table, nil
+}
+
+// CostItem is the estimated monthly cost of one planned resource change.
+type CostItem struct {
+	Address       string
+	Type          string
+	Action        string
+	MonthlyBefore float64
+	MonthlyAfter  float64
+	Note          string
+}
+
+// Delta - השינוי בעלות החודשית של המשאב
+func (i CostItem) Delta() float64 {
+	return i.MonthlyAfter - i.MonthlyBefore
+}
+
+// CostEstimate is the monthly cost delta of a plan.
+type CostEstimate struct {
+	Currency string
+	Items    []CostItem
+	Unpriced []UnpricedResource // משאבים שהשתנו ואי אפשר לתמחר (לא נספרים ב-Delta)
+}
+
+// UnpricedResource is a changed resource left out of the estimate, with the reason when known.
+type UnpricedResource struct {
+	Address string
+	Note    string
+}
+
+// Delta - סך השינוי בעלות החודשית
+func (e *CostEstimate) Delta() float64 {
+	var total float64
+	for _, item := range e.Items {
+		total += item.Delta()
+	}
+	return total
+}
+
+// Log מדפיס את האומדן ל-log viewer, שורה לכל משאב
+func (e *CostEstimate) Log(log *zerolog.Logger) {
+	for _, item := range e.Items {
+		event := log.Info().
+			Str("resource", item.Address).
+			Str("action", item.Action).
+			Float64("before", round2(item.MonthlyBefore)).
+			Float64("after", round2(item.MonthlyAfter)).
+			Float64("delta", round2(item.Delta()))
+		if item.Note != "" {
+			event = event.Str("note", item.Note)
+		}
+		event.Msg("💲 Estimated monthly cost")
+	}
+	for _, u := range e.Unpriced {
+		if u.Note == "" {
+			log.Debug().Str("resource", u.Address).Msg("Resource without a price in the pricing table")
+			continue
+		}
+		log.Info().Str("resource", u.Address).Str("note", u.Note).Msg("⚠️ Resource left out of the cost estimate")
+	}
+	log.Info().
+		Str("currency", e.Currency).
+		Float64("monthly_delta", round2(e.Delta())).
+		Int("priced", len(e.Items)).
+		Int("unpriced", len(e.Unpriced)).
+		Msg("💰 Estimated monthly cost delta for this plan")
+}
+
+// EstimateCost מחשב את השינוי בעלות החודשית של כל משאב ב-plan לפי טבלת המחירים
+func EstimateCost(plan *PlanJSON, pricing *PricingTable) *CostEstimate {
+	estimate := &CostEstimate{Currency: pricing.Currency}
+
+	for _, rc := range plan.ResourceChanges {
+		action := rc.Change.Action()
+		if rc.Mode == "data" || action == "no-op" || action == "read" {
+			continue
+		}
+
+		before, noteBefore, okBefore := pricing.monthlyCost(rc.Type, rc.Change.Before)
+		after, noteAfter, okAfter := pricing.monthlyCost(rc.Type, rc.Change.After)
+		if !okBefore && !okAfter {
+			estimate.Unpriced = append(estimate.Unpriced, UnpricedResource{Address: rc.Address})
+			continue
+		}
+		// רק צד אחד מתומחר (למשל machine type חדש שאין בטבלה) - הצד השני היה נספר כ-0
+		// והשינוי היה נראה כמו כל העלות של המשאב. לא מנחשים: המשאב לא נכנס לאומדן
+		if okBefore != okAfter {
+			note := noteBefore
+			if okBefore {
+				note = noteAfter
+			}
+			estimate.Unpriced = append(estimate.Unpriced, UnpricedResource{
+				Address: rc.Address,
+				Note:    "only one side of the change is priced: " + note,
+			})
+			continue
+		}
+
+		note := noteAfter
+		if note == "" {
+			note = noteBefore
+		}
+		estimate.Items = append(estimate.Items, CostItem{
+			Address:       rc.Address,
+			Type:          rc.Type,
+			Action:        action,
+			MonthlyBefore: before,
+			MonthlyAfter:  after,
+			Note:          note,
+		})
+	}
+
+	sort.Slice(estimate.Items, func(i, j int) bool {
+		return math.Abs(estimate.Items[i].Delta()) > math.Abs(estimate.Items[j].Delta())
+	})
+	return estimate
+}
+
+// monthlyCost מחזיר את העלות החודשית של משאב לפי ה-attributes שלו.
+// attrs ריק (משאב שלא קיים לפני / אחרי) שווה 0. ok=false אם אין תמחור לסוג הזה
+func (p *PricingTable) monthlyCost(resourceType string, attrs map[string]any) (float64, string, bool) {
+	switch resourceType {
+	case "google_compute_instance":
+		if attrs == nil {
+			return 0, "", true
+		}
+		machineType := lastSegment(stringAttr(attrs, "machine_type"))
+		hourly, ok := p.ComputeHourly[machineType]
+		if !ok {
+			return 0, fmt.Sprintf("no price for machine type %q", machineType), false
+		}
+		return hourly * p.HoursPerMonth, "", true
+
+	case "google_cloud_run_v2_service", "google_cloud_run_service":
+		if attrs == nil {
+			return 0, "", true
+		}
+		return p.cloudRunCost(resourceType, attrs)
+
+	case "google_storage_bucket":
+		if attrs == nil {
+			return 0, "", true
+		}
+		class := strings.ToUpper(stringAttr(attrs, "storage_class"))
+		if class == "" {
+			class = "STANDARD"
+		}
+		perGB, ok := p.StorageGBMonth[class]
+		if !ok {
+			return 0, fmt.Sprintf("no price for storage class %q", class), false
+		}
+		return perGB * p.AssumedBucketGB, fmt.Sprintf("assumes %.0f GB stored", p.AssumedBucketGB), true
+
+	case "google_artifact_registry_repository":
+		if attrs == nil {
+			return 0, "", true
+		}
+		return p.ArtifactRegistryGBMonth * p.AssumedRegistryGB, fmt.Sprintf("assumes %.0f GB of images", p.AssumedRegistryGB), true
+	}
+	return 0, "", false
+}
+
+// cloudRunCost - רק מופעים מינימליים עולים כסף קבוע; השאר תלוי בתעבורה
+func (p *PricingTable) cloudRunCost(resourceType string, attrs map[string]any) (float64, string, bool) {
+	var container, scaling map[string]any
+	var minInstances float64
+
+	template := firstBlock(attrs, "template")
+	if resourceType == "google_cloud_run_v2_service" {
+		container = firstBlock(template, "containers")
+		scaling = firstBlock(template, "scaling")
+		minInstances = numberAttr(scaling, "min_instance_count")
+	} else {
+		container = firstBlock(firstBlock(template, "spec"), "containers")
+		annotations, _ := firstBlock(template, "metadata")["annotations"].(map[string]any)
+		if v, ok := annotations["autoscaling.knative.dev/minScale"].(string); ok {
+			minInstances, _ = strconv.ParseFloat(v, 64)
+		}
+	}
+
+	if minInstances == 0 {
+		return 0, "scales to zero - usage based", true
+	}
+
+	cpu, memGiB := p.CloudRun.DefaultCPU, p.CloudRun.DefaultMemoryGiB
+	limits, _ := firstBlock(container, "resources")["limits"].(map[string]any)
+	if v, ok := limits["cpu"].(string); ok {
+		if parsed, err := parseCPU(v); err == nil {
+			cpu = parsed
+		}
+	}
+	if v, ok := limits["memory"].(string); ok {
+		if parsed, err := parseMemoryGiB(v); err == nil {
+			memGiB = parsed
+		}
+	}
+
+	perSecond := cpu*p.CloudRun.VCPUSecond + memGiB*p.CloudRun.GiBSecond
+	return minInstances * perSecond * p.HoursPerMonth * 3600, fmt.Sprintf("%.0f min instance(s) always on", minInstances), true
+}
+
+// firstBlock - ב-plan JSON בלוקים מקוננים הם רשימות; מחזיר את הראשון
+func firstBlock(attrs map[string]any, key string) map[string]any {
+	list, _ := attrs[key].([]any)
+	if len(list) == 0 {
+		return nil
+	}
+	block, _ := list[0].(map[string]any)
+	return block
+}
+
+func stringAttr(attrs map[string]any, key string) string {
+	s, _ := attrs[key].(string)
+	return s
+}
+
+func numberAttr(attrs map[string]any, key string) float64 {
+	n, _ := attrs[key].(float64)
+	return n
+}
+
+// lastSegment - "zones/me-west1-a/machineTypes/e2-medium" -> "e2-medium"
+func lastSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// parseCPU - "1", "2", "1000m"
+func parseCPU(s string) (float64, error) {
+	if strings.HasSuffix(s, "m") {
+		milli, err := strconv.ParseFloat(strings.TrimSuffix(s, "m"), 64)
+		return milli / 1000, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseMemoryGiB - "512Mi", "1Gi", "2G"
+func parseMemoryGiB(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		gib    float64
+	}{
+		{"Gi", 1},
+		{"Mi", 1.0 / 1024},
+		{"G", 1e9 / (1 << 30)},
+		{"M", 1e6 / (1 << 30)},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+			return n * u.gib, err
+		}
+	}
+	return 0, fmt.Errorf("unsupported memory value %q", s)
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,279 @@
+package tfUtils
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testPricing - טבלה קבועה, כדי שהבדיקות לא ישתנו כשמעדכנים את pricing.json
+func testPricing() *PricingTable {
+	return &PricingTable{
+		Currency:      "USD",
+		HoursPerMonth: 730,
+		ComputeHourly: map[string]float64{
+			"e2-small":  0.02,
+			"e2-medium": 0.04,
+		},
+		CloudRun: CloudRunPricing{
+			VCPUSecond:       0.00002,
+			GiBSecond:        0.000002,
+			DefaultCPU:       1,
+			DefaultMemoryGiB: 0.5,
+		},
+		StorageGBMonth:          map[string]float64{"STANDARD": 0.02, "NEARLINE": 0.01},
+		ArtifactRegistryGBMonth: 0.1,
+		AssumedBucketGB:         10,
+		AssumedRegistryGB:       5,
+	}
+}
+
+func parsePlan(t *testing.T, raw string) *PlanJSON {
+	t.Helper()
+	var plan PlanJSON
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+		t.Fatalf("invalid plan fixture: %v", err)
+	}
+	return &plan
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestEstimateCost(t *testing.T) {
+	plan := parsePlan(t, `{
+		"resource_changes": [
+			{
+				"address": "google_compute_instance.vm",
+				"mode": "managed",
+				"type": "google_compute_instance",
+				"change": {
+					"actions": ["update"],
+					"before": {"machine_type": "zones/me-west1-a/machineTypes/e2-small"},
+					"after": {"machine_type": "e2-medium"}
+				}
+			},
+			{
+				"address": "google_compute_instance.big",
+				"mode": "managed",
+				"type": "google_compute_instance",
+				"change": {
+					"actions": ["update"],
+					"before": {"machine_type": "e2-small"},
+					"after": {"machine_type": "c3-highmem-176"}
+				}
+			},
+			{
+				"address": "google_storage_bucket.assets",
+				"mode": "managed",
+				"type": "google_storage_bucket",
+				"change": {
+					"actions": ["create"],
+					"before": null,
+					"after": {"storage_class": "nearline"}
+				}
+			},
+			{
+				"address": "google_pubsub_topic.events",
+				"mode": "managed",
+				"type": "google_pubsub_topic",
+				"change": {"actions": ["create"], "before": null, "after": {"name": "events"}}
+			},
+			{
+				"address": "data.google_project.current",
+				"mode": "data",
+				"type": "google_compute_instance",
+				"change": {"actions": ["read"], "after": {"machine_type": "e2-medium"}}
+			},
+			{
+				"address": "google_compute_instance.unchanged",
+				"mode": "managed",
+				"type": "google_compute_instance",
+				"change": {
+					"actions": ["no-op"],
+					"before": {"machine_type": "e2-medium"},
+					"after": {"machine_type": "e2-medium"}
+				}
+			}
+		]
+	}`)
+
+	estimate := EstimateCost(plan, testPricing())
+
+	items := map[string]CostItem{}
+	for _, item := range estimate.Items {
+		items[item.Address] = item
+	}
+	if len(items) != 2 {
+		t.Fatalf("priced items = %v, want the vm and the bucket", estimate.Items)
+	}
+
+	vm := items["google_compute_instance.vm"]
+	if !almostEqual(vm.MonthlyBefore, 0.02*730) || !almostEqual(vm.MonthlyAfter, 0.04*730) {
+		t.Errorf("vm before/after = %v/%v, want %v/%v", vm.MonthlyBefore, vm.MonthlyAfter, 0.02*730, 0.04*730)
+	}
+	if vm.Action != "update" {
+		t.Errorf("vm action = %q, want update", vm.Action)
+	}
+
+	bucket := items["google_storage_bucket.assets"]
+	if !almostEqual(bucket.MonthlyBefore, 0) || !almostEqual(bucket.MonthlyAfter, 0.01*10) {
+		t.Errorf("bucket before/after = %v/%v, want 0/%v", bucket.MonthlyBefore, bucket.MonthlyAfter, 0.01*10)
+	}
+	if !strings.Contains(bucket.Note, "10 GB") {
+		t.Errorf("bucket note = %q, want the assumed size", bucket.Note)
+	}
+
+	if want := 0.02*730 + 0.1; !almostEqual(estimate.Delta(), want) {
+		t.Errorf("Delta() = %v, want %v", estimate.Delta(), want)
+	}
+	// הגדול קודם
+	if estimate.Items[0].Address != "google_compute_instance.vm" {
+		t.Errorf("items not sorted by delta: %v", estimate.Items)
+	}
+
+	unpriced := map[string]string{}
+	for _, u := range estimate.Unpriced {
+		unpriced[u.Address] = u.Note
+	}
+	if len(unpriced) != 2 {
+		t.Fatalf("unpriced = %v, want the big vm and the topic", estimate.Unpriced)
+	}
+	if note := unpriced["google_compute_instance.big"]; !strings.Contains(note, "only one side") || !strings.Contains(note, "c3-highmem-176") {
+		t.Errorf("big vm note = %q, want the one-side note with the machine type", note)
+	}
+	if note, ok := unpriced["google_pubsub_topic.events"]; !ok || note != "" {
+		t.Errorf("topic unpriced = %q, %v, want listed without a note", note, ok)
+	}
+}
+
+func TestMonthlyCostCloudRun(t *testing.T) {
+	p := testPricing()
+	perSecond := func(cpu, gib float64) float64 {
+		return cpu*p.CloudRun.VCPUSecond + gib*p.CloudRun.GiBSecond
+	}
+	month := p.HoursPerMonth * 3600
+
+	tests := []struct {
+		name     string
+		resource string
+		attrs    string
+		want     float64
+		note     string
+	}{
+		{
+			name:     "v2 scale to zero",
+			resource: "google_cloud_run_v2_service",
+			attrs:    `{"template": [{"scaling": [{"min_instance_count": 0}], "containers": [{}]}]}`,
+			want:     0,
+			note:     "scales to zero",
+		},
+		{
+			name:     "v2 min instances with limits",
+			resource: "google_cloud_run_v2_service",
+			attrs: `{"template": [{
+				"scaling": [{"min_instance_count": 2}],
+				"containers": [{"resources": [{"limits": {"cpu": "2", "memory": "1Gi"}}]}]
+			}]}`,
+			want: 2 * perSecond(2, 1) * month,
+			note: "2 min instance(s)",
+		},
+		{
+			name:     "v2 min instances with defaults",
+			resource: "google_cloud_run_v2_service",
+			attrs:    `{"template": [{"scaling": [{"min_instance_count": 1}], "containers": [{}]}]}`,
+			want:     perSecond(1, 0.5) * month,
+			note:     "1 min instance(s)",
+		},
+		{
+			name:     "v1 minScale annotation",
+			resource: "google_cloud_run_service",
+			attrs: `{"template": [{
+				"metadata": [{"annotations": {"autoscaling.knative.dev/minScale": "1"}}],
+				"spec": [{"containers": [{"resources": [{"limits": {"cpu": "1000m", "memory": "512Mi"}}]}]}]
+			}]}`,
+			want: perSecond(1, 0.5) * month,
+			note: "1 min instance(s)",
+		},
+	}
+	for _, tt := range tests {
+		var attrs map[string]any
+		if err := json.Unmarshal([]byte(tt.attrs), &attrs); err != nil {
+			t.Fatalf("%s: invalid fixture: %v", tt.name, err)
+		}
+		got, note, ok := p.monthlyCost(tt.resource, attrs)
+		if !ok {
+			t.Errorf("%s: ok = false", tt.name)
+			continue
+		}
+		if !almostEqual(got, tt.want) {
+			t.Errorf("%s: monthlyCost() = %v, want %v", tt.name, got, tt.want)
+		}
+		if !strings.Contains(note, tt.note) {
+			t.Errorf("%s: note = %q, want it to contain %q", tt.name, note, tt.note)
+		}
+	}
+}
+
+func TestMonthlyCostStorageClass(t *testing.T) {
+	p := testPricing()
+	tests := []struct {
+		attrs map[string]any
+		want  float64
+		ok    bool
+	}{
+		{map[string]any{}, 0.02 * 10, true}, // ברירת מחדל STANDARD
+		{map[string]any{"storage_class": "STANDARD"}, 0.02 * 10, true},
+		{map[string]any{"storage_class": "nearline"}, 0.01 * 10, true},
+		{map[string]any{"storage_class": "ARCHIVE"}, 0, false}, // לא בטבלה
+		{nil, 0, true}, // נוצר / נמחק
+	}
+	for _, tt := range tests {
+		got, _, ok := p.monthlyCost("google_storage_bucket", tt.attrs)
+		if ok != tt.ok || !almostEqual(got, tt.want) {
+			t.Errorf("monthlyCost(bucket %v) = %v, %v, want %v, %v", tt.attrs, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseMemoryGiB(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"1Gi", 1},
+		{"2Gi", 2},
+		{"512Mi", 0.5},
+		{"256Mi", 0.25},
+		{"2G", 2e9 / (1 << 30)},
+		{"500M", 500e6 / (1 << 30)},
+	}
+	for _, tt := range tests {
+		got, err := parseMemoryGiB(tt.value)
+		if err != nil {
+			t.Errorf("parseMemoryGiB(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !almostEqual(got, tt.want) {
+			t.Errorf("parseMemoryGiB(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"1Ti", "lots", "Gi"} {
+		if _, err := parseMemoryGiB(bad); err == nil {
+			t.Errorf("parseMemoryGiB(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+func TestParseCPU(t *testing.T) {
+	tests := map[string]float64{"1": 1, "2": 2, "1000m": 1, "500m": 0.5}
+	for value, want := range tests {
+		got, err := parseCPU(value)
+		if err != nil || !almostEqual(got, want) {
+			t.Errorf("parseCPU(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+}
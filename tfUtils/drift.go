@@ -0,0 +1,180 @@
+package tfUtils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"DevOps/history"
+
+	"github.com/rs/zerolog"
+)
+
+// DriftHistoryKind - סוג הרשומה של בדיקות drift בהיסטוריית ההרצות
+const DriftHistoryKind = "terraform-drift"
+
+// driftPlanFile - קובץ ה-plan של בדיקת ה-drift (נמחק בסוף הבדיקה)
+const driftPlanFile = "driftplan"
+
+// DriftedResource is one resource whose real infrastructure differs from the state.
+type DriftedResource struct {
+	Address    string   `json:"address"`
+	Type       string   `json:"type"`
+	Action     string   `json:"action"`               // update / delete (המשאב נמחק מחוץ ל-Terraform)
+	Attributes []string `json:"attributes,omitempty"` // השדות שהשתנו
+}
+
+// DriftReport is the result of one drift check of a stack.
+type DriftReport struct {
+	ProjectID string            `json:"project"`
+	Dir       string            `json:"dir"`
+	Workspace string            `json:"workspace"`
+	Checked   time.Time         `json:"checked"`
+	Drifted   bool              `json:"drifted"`
+	Resources []DriftedResource `json:"resources"`
+}
+
+// Log מדפיס את תוצאת בדיקת ה-drift ל-log viewer
+func (r *DriftReport) Log(log *zerolog.Logger) {
+	for _, res := range r.Resources {
+		log.Warn().
+			Str("resource", res.Address).
+			Str("action", res.Action).
+			Strs("attributes", res.Attributes).
+			Msg("🌀 Drift detected")
+	}
+	if r.Drifted {
+		log.Warn().Str("project", r.ProjectID).Str("dir", r.Dir).Str("workspace", r.Workspace).Int("resources", len(r.Resources)).Msg("⚠️ Infrastructure has drifted from Terraform state")
+	} else {
+		log.Info().Str("project", r.ProjectID).Str("dir", r.Dir).Str("workspace", r.Workspace).Msg("✅ No drift - infrastructure matches Terraform state")
+	}
+}
+
+// changedAttributes מחזיר את שמות השדות העליונים שערכם שונה בין before ל-after
+func changedAttributes(before, after map[string]any) []string {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	var changed []string
+	for k := range keys {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// driftedResources מפרק את resource_drift של ה-plan
+func driftedResources(plan *PlanJSON) []DriftedResource {
+	var resources []DriftedResource
+	for _, rc := range plan.ResourceDrift {
+		if rc.Mode == "data" {
+			continue
+		}
+		resources = append(resources, DriftedResource{
+			Address:    rc.Address,
+			Type:       rc.Type,
+			Action:     rc.Change.Action(),
+			Attributes: changedAttributes(rc.Change.Before, rc.Change.After),
+		})
+	}
+	return resources
+}
+
+// DetectDrift runs a refresh-only plan for the stack in opts and reports resources that
+// changed outside Terraform. The result is recorded in run history.
+func DetectDrift(log *zerolog.Logger, opts TerraformOptions) (report *DriftReport, err error) {
+	log.Info().Str("dir", opts.TerraformDir).Str("project", opts.ProjectID).Str("workspace", workspaceName(opts.Workspace)).Msg("🔎 Checking for Terraform drift...")
+
+	workspace := workspaceName(opts.Workspace)
+	report = &DriftReport{ProjectID: opts.ProjectID, Dir: opts.TerraformDir, Workspace: workspace, Checked: time.Now()}
+
+	entry := history.NewEntry(DriftHistoryKind)
+	entry.Details["project"] = opts.ProjectID
+	entry.Details["dir"] = opts.TerraformDir
+	entry.Details["workspace"] = workspace
+	defer func() {
+		entry.Finish(err)
+		if err == nil {
+			entry.Details["drifted"] = strconv.FormatBool(report.Drifted)
+			entry.Details["resources"] = strconv.Itoa(len(report.Resources))
+			if data, jsonErr := json.Marshal(report.Resources); jsonErr == nil {
+				entry.Details["drift"] = string(data)
+			}
+		}
+		if recErr := history.Record(entry); recErr != nil {
+			log.Warn().Err(recErr).Msg("⚠️ Failed to record drift check in run history")
+		}
+	}()
+
+	config := opts.tfConfig()
+	if err := Init(log, config); err != nil {
+		return nil, err
+	}
+
+	// קוד יציאה 0 = אין drift, 2 = יש drift, כל השאר = כשלון
+	env, varArgs, cleanup, err := prepareVars(log, config)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"plan", "-refresh-only", "-detailed-exitcode", "-input=false", "-out=" + driftPlanFile}, planArgs(config)...)
+	_, code, err := RunTerraformExitCodeWithEnv(log, config.Dir, env, []int{2}, append(args, varArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("refresh-only plan failed: %w", err)
+	}
+	defer os.Remove(filepath.Join(config.Dir, driftPlanFile))
+
+	if code == 2 {
+		plan, err := ShowPlan(log, config.Dir, driftPlanFile)
+		if err != nil {
+			return nil, err
+		}
+		report.Resources = driftedResources(plan)
+		report.Drifted = len(report.Resources) > 0
+	}
+
+	report.Log(log)
+	return report, nil
+}
+
+// LatestDriftReports מחזיר את בדיקת ה-drift האחרונה של כל stack (פרויקט + תיקייה + workspace) מההיסטוריה
+func LatestDriftReports() ([]history.Entry, error) {
+	entries, err := history.List(DriftHistoryKind)
+	if err != nil {
+		return nil, err
+	}
+	return latestPerStack(entries), nil
+}
+
+// latestPerStack - הרשומה האחרונה לכל פרויקט + תיקייה + workspace. רשומות ישנות
+// בלי workspace שייכות ל-default
+func latestPerStack(entries []history.Entry) []history.Entry {
+	latest := map[string]history.Entry{}
+	var order []string
+	for _, e := range entries {
+		key := e.Details["project"] + "|" + e.Details["dir"] + "|" + workspaceName(e.Details["workspace"])
+		if _, seen := latest[key]; !seen {
+			order = append(order, key)
+		}
+		latest[key] = e // הרשימה מהישנה לחדשה - האחרונה מנצחת
+	}
+
+	sort.Strings(order)
+	result := make([]history.Entry, 0, len(order))
+	for _, key := range order {
+		result = append(result, latest[key])
+	}
+	return result
+}
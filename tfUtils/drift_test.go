@@ -0,0 +1,45 @@
+package tfUtils
+
+import (
+	"testing"
+
+	"DevOps/history"
+)
+
+func driftEntry(id, project, dir, workspace string) history.Entry {
+	details := map[string]string{"project": project, "dir": dir}
+	if workspace != "" {
+		details["workspace"] = workspace
+	}
+	return history.Entry{ID: id, Kind: DriftHistoryKind, Details: details}
+}
+
+func TestLatestPerStack(t *testing.T) {
+	entries := []history.Entry{ // מהישנה לחדשה
+		driftEntry("1", "p", "infra", ""), // רשומה ישנה בלי workspace = default
+		driftEntry("2", "p", "infra", "staging"),
+		driftEntry("3", "p", "infra", "default"),
+		driftEntry("4", "p", "infra", "prod"),
+		driftEntry("5", "p", "infra", "staging"),
+		driftEntry("6", "other", "infra", "staging"),
+	}
+
+	got := map[string]string{}
+	for _, e := range latestPerStack(entries) {
+		got[e.Details["project"]+"|"+workspaceName(e.Details["workspace"])] = e.ID
+	}
+	want := map[string]string{
+		"p|default":     "3",
+		"p|staging":     "5",
+		"p|prod":        "4",
+		"other|staging": "6",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("latestPerStack() = %v, want %v", got, want)
+	}
+	for key, id := range want {
+		if got[key] != id {
+			t.Errorf("latest %s = %q, want %q", key, got[key], id)
+		}
+	}
+}
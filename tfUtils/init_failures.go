@@ -0,0 +1,151 @@
+package tfUtils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// InitFailure - סוג הכישלון של terraform init, לפי הפלט שלו
+type InitFailure string
+
+const (
+	InitFailureBackendChanged InitFailure = "backend-changed"
+	InitFailureLockMismatch   InitFailure = "lock-mismatch"
+	InitFailurePluginDownload InitFailure = "plugin-download"
+	InitFailureAuth           InitFailure = "auth"
+	InitFailureUnknown        InitFailure = "unknown"
+)
+
+var (
+	// ErrBackendChanged - ה-backend השתנה ולא אושרה העתקה של ה-state או reconfigure
+	ErrBackendChanged = errors.New("terraform backend configuration changed: re-run with -migrate-state to copy the existing state, or -reconfigure to start from the new backend without copying it")
+	// ErrInitAuth - Terraform לא הצליח להזדהות מול GCP / ה-backend
+	ErrInitAuth = errors.New("terraform init failed to authenticate")
+)
+
+// initDownloadAttempts / initRetryDelay - ניסיונות חוזרים כשהורדת providers נכשלת (תקלת רשת)
+var (
+	initDownloadAttempts = 3
+	initRetryDelay       = 5 * time.Second
+)
+
+// הסדר חשוב: שגיאת lock או backend יכולה להכיל גם טקסט שנראה כמו תקלת רשת
+var initFailurePatterns = []struct {
+	failure  InitFailure
+	patterns []string
+}{
+	{InitFailureBackendChanged, []string{
+		"backend configuration changed",
+		"backend configuration block has changed",
+		"backend initialization required",
+		"-reconfigure or -migrate-state",
+	}},
+	{InitFailureLockMismatch, []string{
+		"inconsistent dependency lock file",
+		"does not match configured version constraint",
+		"provider dependency changes detected",
+		"the current dependency lock file",
+		"doesn't match any of the checksums",
+	}},
+	{InitFailureAuth, []string{
+		"could not find default credentials",
+		"oauth2: cannot fetch token",
+		"invalid_grant",
+		"googleapi: error 401",
+		"googleapi: error 403",
+		// רק הנוסח של GCP - "permission denied" לבד הוא גם שגיאת קובץ מקומית (plugin cache, .terraform)
+		"permission_denied",
+		"caller does not have permission",
+		"iam.serviceaccounts.getaccesstoken",
+		"does not have storage.",
+	}},
+	{InitFailurePluginDownload, []string{
+		"failed to query available provider packages",
+		"failed to install provider",
+		"could not connect to registry",
+		"error while installing",
+		"i/o timeout",
+		"tls handshake timeout",
+		"connection reset by peer",
+		"no such host",
+		"context deadline exceeded",
+	}},
+}
+
+// classifyInitFailure מזהה את סוג הכישלון לפי הפלט של terraform init
+func classifyInitFailure(output string) InitFailure {
+	lower := strings.ToLower(output)
+	for _, p := range initFailurePatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				return p.failure
+			}
+		}
+	}
+	return InitFailureUnknown
+}
+
+// initBackendChanged - העתקת state רק באישור מפורש (MigrateState או הקלדה בטרמינל),
+// reconfigure רק כשהתבקש. בלי אחד מהם - עוצרים עם שגיאה שמסבירה את האפשרויות.
+// בהרצה לא אינטראקטיבית לא שואלים בכלל
+func initBackendChanged(log *zerolog.Logger, config TFConfig, env, baseArgs []string) error {
+	migrate := config.MigrateState
+	if !migrate && !config.Reconfigure {
+		log.Warn().Str("dir", config.Dir).Msg("🔀 Terraform backend configuration changed - existing state must be migrated or dropped")
+		if config.NonInteractive {
+			log.Error().Str("dir", config.Dir).Msg("❌ State migration needs confirmation and this run is not interactive")
+			return ErrBackendChanged
+		}
+		answer, err := readConfirmation("Type 'migrate' to copy the existing state to the new backend: ")
+		migrate = err == nil && answer == "migrate"
+		if !migrate {
+			log.Error().Str("dir", config.Dir).Msg("❌ State migration not confirmed")
+			return ErrBackendChanged
+		}
+	}
+
+	if migrate {
+		log.Warn().Str("dir", config.Dir).Msg("🚚 Migrating Terraform state to the new backend...")
+		// -force-copy עונה "כן" לשאלה של Terraform - האישור כבר התקבל כאן
+		_, err := RunTerraformWithEnv(log, config.Dir, env, append(baseArgs, "-migrate-state", "-force-copy")...)
+		return err
+	}
+
+	log.Warn().Str("dir", config.Dir).Msg("🔄 Reconfiguring Terraform backend without migrating state...")
+	_, err := RunTerraformWithEnv(log, config.Dir, env, append(baseArgs, "-reconfigure")...)
+	return err
+}
+
+// initLockMismatch - ה-lock file לא תואם את ה-providers. -upgrade משנה את גרסאות ה-providers,
+// לכן לא מריצים אותו מעצמנו - רק מסבירים איך לעדכן את ה-lock file
+func initLockMismatch(log *zerolog.Logger, config TFConfig, initErr error) error {
+	if config.Providers.Upgrade {
+		log.Error().Str("dir", config.Dir).Msg("❌ Provider lock file does not match even with -upgrade - check the provider version constraints")
+	} else {
+		log.Error().Str("dir", config.Dir).Msg("🔒 Provider lock file does not match the configuration - re-run with -upgrade to update it")
+	}
+	return initErr
+}
+
+// initRetryDownload - תקלת רשת בהורדת providers: ניסיון חוזר עם המתנה הולכת וגדלה
+func initRetryDownload(log *zerolog.Logger, config TFConfig, env, baseArgs []string) error {
+	var err error
+	for attempt := 2; attempt <= initDownloadAttempts; attempt++ {
+		delay := initRetryDelay * time.Duration(attempt-1)
+		log.Warn().Int("attempt", attempt).Dur("delay", delay).Msg("🔁 Provider download failed - retrying terraform init...")
+		time.Sleep(delay)
+
+		var out string
+		if out, err = RunTerraformWithEnv(log, config.Dir, env, baseArgs...); err == nil {
+			return nil
+		}
+		if failure := classifyInitFailure(out); failure != InitFailurePluginDownload {
+			return fmt.Errorf("terraform init failed (%s): %w", failure, err)
+		}
+	}
+	return fmt.Errorf("terraform init failed to download providers after %d attempts: %w", initDownloadAttempts, err)
+}
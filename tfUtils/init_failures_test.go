@@ -0,0 +1,179 @@
+package tfUtils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// הפלטים מבוססים על הודעות השגיאה של terraform init (כולל המסגרת │ / ╷ / ╵)
+func TestClassifyInitFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   InitFailure
+	}{
+		{
+			name: "backend configuration changed",
+			output: `Initializing the backend...
+╷
+│ Error: Backend configuration changed
+│ 
+│ A change in the backend configuration has been detected, which may require migrating existing state.
+│ 
+│ If you wish to attempt automatic migration of the state, use "terraform init -migrate-state".
+│ If you wish to store the current configuration with no changes to the state, use "terraform init -reconfigure".
+╵`,
+			want: InitFailureBackendChanged,
+		},
+		{
+			name: "backend initialization required",
+			output: `╷
+│ Error: Backend initialization required, please run "terraform init"
+│ 
+│ Reason: Unsetting the previously set backend "gcs"
+╵`,
+			want: InitFailureBackendChanged,
+		},
+		{
+			// הסדר: שינוי backend שבמקרה מכיל גם טקסט של תקלת רשת הוא עדיין שינוי backend
+			name: "backend changed with network text",
+			output: `Initializing the backend...
+╷
+│ Error: Backend configuration changed
+│ 
+│ A change in the backend configuration has been detected, which may require migrating existing state.
+│ 
+│ Error: Failed to get existing workspaces: querying Cloud Storage failed: Get "https://storage.googleapis.com/storage/v1/b/proj-tfstate/o": dial tcp: lookup storage.googleapis.com: no such host
+╵`,
+			want: InitFailureBackendChanged,
+		},
+		{
+			name: "lock file inconsistent",
+			output: `Initializing provider plugins...
+- Reusing previous version of hashicorp/google from the dependency lock file
+╷
+│ Error: Inconsistent dependency lock file
+│ 
+│ The following dependency selections recorded in the lock file are inconsistent with the current configuration:
+│   - provider registry.terraform.io/hashicorp/google: locked version selection 5.45.0 doesn't match the updated version constraints "~> 6.0"
+│ 
+│ To update the locked dependency selections to match a changed configuration, run:
+│   terraform init -upgrade
+╵`,
+			want: InitFailureLockMismatch,
+		},
+		{
+			// הסדר: checksum שלא תואם מגיע בתוך "Failed to install provider" - זה lock ולא רשת
+			name: "lock checksum mismatch inside install error",
+			output: `╷
+│ Error: Failed to install provider
+│ 
+│ Error while installing hashicorp/google v6.10.0: the local package for registry.terraform.io/hashicorp/google 6.10.0 doesn't match any of the checksums previously recorded in the dependency lock file (for this platform)
+╵`,
+			want: InitFailureLockMismatch,
+		},
+		{
+			name: "no default credentials",
+			output: `Initializing the backend...
+╷
+│ Error: storage.NewClient() failed: dialing: google: could not find default credentials. See https://cloud.google.com/docs/authentication/external/set-up-adc for more information
+╵`,
+			want: InitFailureAuth,
+		},
+		{
+			name: "bucket permission denied",
+			output: `╷
+│ Error: Failed to get existing workspaces: querying Cloud Storage failed: googleapi: Error 403: deploy@proj.iam.gserviceaccount.com does not have storage.objects.list access to the Google Cloud Storage bucket. Permission 'storage.objects.list' denied on resource (or it may not exist)., forbidden
+╵`,
+			want: InitFailureAuth,
+		},
+		{
+			name: "expired user credentials",
+			output: `╷
+│ Error: Failed to get existing workspaces: querying Cloud Storage failed: Get "https://storage.googleapis.com/storage/v1/b/proj-tfstate/o": oauth2: "invalid_grant" "reauth related error (invalid_rapt)"
+╵`,
+			want: InitFailureAuth,
+		},
+		{
+			name: "impersonation denied",
+			output: `╷
+│ Error: Failed to get existing workspaces: querying Cloud Storage failed: impersonate: status code 403: {
+│   "error": {
+│     "code": 403,
+│     "message": "Permission 'iam.serviceAccounts.getAccessToken' denied on resource (or it may not exist).",
+│     "status": "PERMISSION_DENIED"
+│   }
+│ }
+╵`,
+			want: InitFailureAuth,
+		},
+		{
+			name: "registry unreachable",
+			output: `Initializing provider plugins...
+- Finding hashicorp/google versions matching "~> 6.0"...
+╷
+│ Error: Failed to query available provider packages
+│ 
+│ Could not retrieve the list of available versions for provider hashicorp/google: could not connect to registry.terraform.io: failed to request discovery document: Get "https://registry.terraform.io/.well-known/terraform.json": dial tcp: lookup registry.terraform.io: no such host
+╵`,
+			want: InitFailurePluginDownload,
+		},
+		{
+			name: "download connection reset",
+			output: `- Installing hashicorp/google v6.10.0...
+╷
+│ Error: Failed to install provider
+│ 
+│ Error while installing hashicorp/google v6.10.0: read tcp 10.0.0.2:51234->140.82.112.4:443: read: connection reset by peer
+╵`,
+			want: InitFailurePluginDownload,
+		},
+		{
+			// "permission denied" של קובץ מקומי הוא לא בעיית הזדהות מול GCP
+			name: "local file permission denied",
+			output: `╷
+│ Error: Failed to read file
+│ 
+│ The file "backend.tfvars" could not be read: open backend.tfvars: permission denied
+╵`,
+			want: InitFailureUnknown,
+		},
+		{
+			name: "configuration error",
+			output: `╷
+│ Error: Unsupported argument
+│ 
+│   on backend.tf line 3, in terraform:
+│    3:     buckett = "proj-tfstate"
+│ 
+│ An argument named "buckett" is not expected here.
+╵`,
+			want: InitFailureUnknown,
+		},
+		{
+			name:   "empty output",
+			output: "",
+			want:   InitFailureUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyInitFailure(tt.output); got != tt.want {
+				t.Errorf("classifyInitFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitBackendChangedNonInteractive(t *testing.T) {
+	log := zerolog.Nop()
+	stdinWith(t, "migrate\n")
+
+	config := TFConfig{Dir: t.TempDir(), NonInteractive: true}
+	if err := initBackendChanged(&log, config, nil, []string{"init"}); !errors.Is(err, ErrBackendChanged) {
+		t.Errorf("initBackendChanged(non-interactive) error = %v, want ErrBackendChanged", err)
+	}
+}
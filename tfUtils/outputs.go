@@ -0,0 +1,188 @@
+package tfUtils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maskedValue - מה שמוצג במקום ערך רגיש (log viewer / API)
+const maskedValue = "(sensitive)"
+
+// Output is one typed value from `terraform output -json`.
+type Output struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"` // string / number / bool / list / map / object / tuple / set
+	Sensitive bool   `json:"sensitive"`
+	Value     any    `json:"value"`
+}
+
+// Masked מחזיר עותק שבו ערך רגיש מוחלף ב-maskedValue
+func (o Output) Masked() Output {
+	if o.Sensitive {
+		o.Value = maskedValue
+	}
+	return o
+}
+
+// VarValue - הערך כמחרוזת (למשל לרישום ערך רגיש ב-logger): מחרוזת כמו שהיא,
+// וכל השאר (מספר, רשימה, map) בקידוד JSON
+func (o Output) VarValue() string {
+	if s, ok := o.Value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(o.Value)
+	return string(data)
+}
+
+// Outputs - ה-outputs של stack לפי שם
+type Outputs map[string]Output
+
+// String מחזיר output מסוג string (ok=false אם אין כזה או שהוא מסוג אחר)
+func (o Outputs) String(name string) (string, bool) {
+	s, ok := o[name].Value.(string)
+	return s, ok && s != ""
+}
+
+// Number מחזיר output מספרי
+func (o Outputs) Number(name string) (float64, bool) {
+	n, ok := o[name].Value.(float64)
+	return n, ok
+}
+
+// Bool מחזיר output בוליאני
+func (o Outputs) Bool(name string) (bool, bool) {
+	b, ok := o[name].Value.(bool)
+	return b, ok
+}
+
+// Masked מחזיר עותק שבו כל הערכים הרגישים מוסתרים
+func (o Outputs) Masked() Outputs {
+	masked := make(Outputs, len(o))
+	for name, out := range o {
+		masked[name] = out.Masked()
+	}
+	return masked
+}
+
+// Log מדפיס את ה-outputs ל-log viewer. ערכים רגישים לא נכתבים ללוג
+func (o Outputs) Log(log *zerolog.Logger, dir string) {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		out := o[name].Masked()
+		log.Info().
+			Str("stack", dir).
+			Str("output", name).
+			Str("type", out.Type).
+			Bool("sensitive", out.Sensitive).
+			Interface("value", out.Value).
+			Msg("📤 Terraform output")
+	}
+}
+
+// MergeOutputs מאחד outputs של כמה stacks לפי הסדר - stack מאוחר גובר על קודם
+func MergeOutputs(all ...Outputs) Outputs {
+	merged := Outputs{}
+	for _, outs := range all {
+		for name, out := range outs {
+			merged[name] = out
+		}
+	}
+	return merged
+}
+
+// outputTypeName - ה-type ב-JSON הוא "string" או ["list","string"] / ["object",{...}]
+func outputTypeName(raw json.RawMessage) string {
+	var simple string
+	if err := json.Unmarshal(raw, &simple); err == nil {
+		return simple
+	}
+	var complex []json.RawMessage
+	if err := json.Unmarshal(raw, &complex); err == nil && len(complex) > 0 {
+		var kind string
+		if err := json.Unmarshal(complex[0], &kind); err == nil {
+			return kind
+		}
+	}
+	return "unknown"
+}
+
+// ReadOutputs runs `terraform output -json` in dir and returns the typed outputs.
+func ReadOutputs(log *zerolog.Logger, dir string) (Outputs, error) {
+	out, err := RunTerraformOutput(log, dir, "output", "-json")
+	if err != nil {
+		return nil, fmt.Errorf("terraform output failed: %w", err)
+	}
+
+	var raw map[string]struct {
+		Sensitive bool            `json:"sensitive"`
+		Type      json.RawMessage `json:"type"`
+		Value     any             `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform outputs: %w", err)
+	}
+
+	outputs := make(Outputs, len(raw))
+	for name, o := range raw {
+		outputs[name] = Output{
+			Name:      name,
+			Type:      outputTypeName(o.Type),
+			Sensitive: o.Sensitive,
+			Value:     o.Value,
+		}
+	}
+	return outputs, nil
+}
+
+// StackOutputs - ה-outputs האחרונים של stack, כפי שמוצגים ב-web API (מוסתרים)
+type StackOutputs struct {
+	ProjectID string    `json:"project"`
+	Dir       string    `json:"dir"`
+	Updated   time.Time `json:"updated"`
+	Outputs   Outputs   `json:"outputs"`
+}
+
+var (
+	outputsMu     sync.Mutex
+	latestOutputs = map[string]StackOutputs{}
+)
+
+// rememberOutputs שומר את ה-outputs האחרונים של ה-stack עבור ה-API (רק בגרסה המוסתרת)
+func rememberOutputs(projectID, dir string, outputs Outputs) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	latestOutputs[projectID+"|"+dir] = StackOutputs{
+		ProjectID: projectID,
+		Dir:       dir,
+		Updated:   time.Now(),
+		Outputs:   outputs.Masked(),
+	}
+}
+
+// LatestOutputs מחזיר את ה-outputs האחרונים של כל stack שהורץ בתהליך הזה
+func LatestOutputs() []StackOutputs {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+
+	result := make([]StackOutputs, 0, len(latestOutputs))
+	for _, s := range latestOutputs {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ProjectID != result[j].ProjectID {
+			return result[i].ProjectID < result[j].ProjectID
+		}
+		return result[i].Dir < result[j].Dir
+	})
+	return result
+}
@@ -0,0 +1,95 @@
+package tfUtils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOutputTypeName(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"string"`, "string"},
+		{`"number"`, "number"},
+		{`"bool"`, "bool"},
+		{`["list","string"]`, "list"},
+		{`["map","number"]`, "map"},
+		{`["object",{"name":"string","port":"number"}]`, "object"},
+		{`["tuple",["string","number"]]`, "tuple"},
+		{`["set","string"]`, "set"},
+		{`[]`, "unknown"},
+		{`42`, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := outputTypeName(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("outputTypeName(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestOutputMasked(t *testing.T) {
+	secret := Output{Name: "db_password", Type: "string", Sensitive: true, Value: "hunter22"}
+	masked := secret.Masked()
+	if masked.Value != maskedValue {
+		t.Errorf("Masked().Value = %v, want %q", masked.Value, maskedValue)
+	}
+	if secret.Value != "hunter22" {
+		t.Errorf("Masked() changed the original value to %v", secret.Value)
+	}
+	if masked.Name != secret.Name || masked.Type != secret.Type || !masked.Sensitive {
+		t.Errorf("Masked() = %+v, want name, type and sensitive kept", masked)
+	}
+
+	plain := Output{Name: "region", Type: "string", Value: "me-west1"}
+	if got := plain.Masked(); got.Value != "me-west1" {
+		t.Errorf("Masked() of a non-sensitive output = %v, want me-west1", got.Value)
+	}
+}
+
+func TestOutputsMasked(t *testing.T) {
+	outputs := Outputs{
+		"url":   {Name: "url", Type: "string", Value: "https://app"},
+		"token": {Name: "token", Type: "string", Sensitive: true, Value: "s3cr3t-token"},
+		"ports": {Name: "ports", Type: "list", Sensitive: true, Value: []any{80.0, 443.0}},
+	}
+	masked := outputs.Masked()
+
+	if masked["url"].Value != "https://app" {
+		t.Errorf("url = %v, want https://app", masked["url"].Value)
+	}
+	for _, name := range []string{"token", "ports"} {
+		if masked[name].Value != maskedValue {
+			t.Errorf("%s = %v, want %q", name, masked[name].Value, maskedValue)
+		}
+	}
+	if outputs["token"].Value != "s3cr3t-token" {
+		t.Errorf("Masked() changed the original outputs")
+	}
+}
+
+func TestMergeOutputs(t *testing.T) {
+	network := Outputs{
+		"network_name": {Name: "network_name", Type: "string", Value: "main"},
+		"region":       {Name: "region", Type: "string", Value: "me-west1"},
+	}
+	app := Outputs{
+		"region":      {Name: "region", Type: "string", Value: "europe-west1"},
+		"service_url": {Name: "service_url", Type: "string", Value: "https://app"},
+	}
+
+	got := MergeOutputs(network, nil, app)
+	want := Outputs{
+		"network_name": network["network_name"],
+		"region":       app["region"], // stack מאוחר גובר
+		"service_url":  app["service_url"],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeOutputs() = %+v, want %+v", got, want)
+	}
+
+	if got := MergeOutputs(); got == nil || len(got) != 0 {
+		t.Errorf("MergeOutputs() with no input = %#v, want empty non-nil Outputs", got)
+	}
+}
@@ -0,0 +1,155 @@
+package tfUtils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultPlanFile - שם קובץ ה-plan הבינארי שנשמר בתיקיית ה-Terraform בין plan ל-apply
+const DefaultPlanFile = "tfplan"
+
+// PlanJSON is the subset of `terraform show -json <planfile>` that the workflow uses.
+type PlanJSON struct {
+	FormatVersion   string                  `json:"format_version"`
+	ResourceChanges []ResourceChange        `json:"resource_changes"`
+	ResourceDrift   []ResourceChange        `json:"resource_drift"`
+	OutputChanges   map[string]OutputChange `json:"output_changes"`
+}
+
+// OutputChange is one entry of output_changes in the plan JSON (values are not needed).
+type OutputChange struct {
+	Actions []string `json:"actions"`
+}
+
+// ResourceChange is one entry of resource_changes in the plan JSON.
+type ResourceChange struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Change  Change `json:"change"`
+}
+
+// Change holds the planned actions and the before/after attribute values of a resource.
+type Change struct {
+	Actions []string       `json:"actions"`
+	Before  map[string]any `json:"before"`
+	After   map[string]any `json:"after"`
+}
+
+// Action מחזיר פעולה אחת קריאה: create / update / delete / replace / no-op / read
+func (c Change) Action() string {
+	switch len(c.Actions) {
+	case 0:
+		return "no-op"
+	case 1:
+		return c.Actions[0]
+	default:
+		// ["delete","create"] או ["create","delete"] - החלפה
+		return "replace"
+	}
+}
+
+// PlanSummary - ספירת השינויים ב-plan, ואומדן העלות אם חושב
+type PlanSummary struct {
+	Create  int
+	Update  int
+	Delete  int
+	Replace int
+	NoOp    int
+	Outputs int // outputs שמשתנים (גם בלי שינוי במשאבים, apply מעדכן אותם ב-state)
+	Cost    *CostEstimate
+}
+
+// HasChanges reports whether the plan changes any resource or output.
+func (s PlanSummary) HasChanges() bool {
+	return s.Create+s.Update+s.Delete+s.Replace+s.Outputs > 0
+}
+
+// Summarize סופר את השינויים ב-plan לפי סוג פעולה (משאבי data לא נספרים)
+func Summarize(plan *PlanJSON) PlanSummary {
+	var s PlanSummary
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode == "data" {
+			continue
+		}
+		switch rc.Change.Action() {
+		case "create":
+			s.Create++
+		case "update":
+			s.Update++
+		case "delete":
+			s.Delete++
+		case "replace":
+			s.Replace++
+		default:
+			s.NoOp++
+		}
+	}
+	for _, oc := range plan.OutputChanges {
+		if len(oc.Actions) > 0 && !(len(oc.Actions) == 1 && oc.Actions[0] == "no-op") {
+			s.Outputs++
+		}
+	}
+	return s
+}
+
+// Log מדפיס את סיכום ה-plan ל-log viewer
+func (s PlanSummary) Log(log *zerolog.Logger) {
+	event := log.Info().
+		Int("create", s.Create).
+		Int("update", s.Update).
+		Int("delete", s.Delete).
+		Int("replace", s.Replace).
+		Int("unchanged", s.NoOp).
+		Int("outputs_changed", s.Outputs)
+	if s.Cost != nil {
+		event = event.
+			Str("currency", s.Cost.Currency).
+			Float64("monthly_cost_delta", round2(s.Cost.Delta()))
+	}
+	event.Msg("📋 Terraform plan summary")
+}
+
+// planArgs - משתני ה-var-file וה-vars המשותפים ל-plan ול-apply
+func planArgs(config TFConfig) []string {
+	var args []string
+	if config.VarFile != "" {
+		args = append(args, fmt.Sprintf("-var-file=%s", config.VarFile))
+	}
+	// Vars לא עוברים בשורת הפקודה - ראו prepareVars
+	return args
+}
+
+// Plan מריץ terraform plan ושומר את התוצאה ב-planFile (יחסי לתיקיית ה-Terraform)
+func Plan(log *zerolog.Logger, config TFConfig, planFile string) error {
+	log.Info().Str("plan_file", planFile).Msg("📝 Running Terraform Plan...")
+	args := append([]string{"plan", "-input=false", "-out=" + planFile}, planArgs(config)...)
+	args = append(args, config.Targeting.args()...)
+	_, err := runWithVars(log, config, args...)
+	return err
+}
+
+// ShowPlan קורא קובץ plan שמור כ-JSON
+func ShowPlan(log *zerolog.Logger, dir, planFile string) (*PlanJSON, error) {
+	out, err := RunTerraformOutput(log, dir, "show", "-json", planFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", planFile, err)
+	}
+
+	var plan PlanJSON
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+	return &plan, nil
+}
+
+// ApplyPlan מחיל בדיוק את ה-plan שנשמר (מה שהוערך הוא מה שמוחל)
+func ApplyPlan(log *zerolog.Logger, config TFConfig, planFile string) error {
+	log.Info().Str("plan_file", planFile).Msg("🚀 Running Terraform Apply (saved plan)...")
+	args := append([]string{"apply", "-input=false"}, config.Targeting.parallelismArgs()...)
+	_, err := RunTerraform(log, config.Dir, append(args, planFile)...)
+	return err
+}
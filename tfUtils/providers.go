@@ -0,0 +1,129 @@
+package tfUtils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultProviderMirrorDir - תיקיית ה-mirror המקומי של ה-providers (מצב offline)
+	DefaultProviderMirrorDir = "terraform-providers"
+	// lockFileName - קובץ ה-lock של ה-providers, נשמר ב-git ליד קבצי ה-Terraform
+	lockFileName = ".terraform.lock.hcl"
+)
+
+// ProviderSettings - איך terraform init מתקין providers
+type ProviderSettings struct {
+	CacheDir  string // plugin cache משותף לכל ה-stacks (ברירת מחדל: DefaultPluginCacheDir, "-" = בלי cache)
+	Upgrade   bool   // init -upgrade: לעדכן providers וה-lock file. בלי זה ה-lock file מחייב
+	Offline   bool   // התקנה רק מה-mirror המקומי, בלי גישה לרשת
+	MirrorDir string // ברירת מחדל: DefaultProviderMirrorDir
+}
+
+// DefaultPluginCacheDir - ה-plugin cache המשותף, בתיקיית ה-cache של המשתמש
+func DefaultPluginCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "terraform", "plugin-cache")
+}
+
+func (p ProviderSettings) cacheDir() string {
+	switch p.CacheDir {
+	case "-":
+		return ""
+	case "":
+		return DefaultPluginCacheDir()
+	default:
+		return p.CacheDir
+	}
+}
+
+func (p ProviderSettings) mirrorDir() string {
+	if p.MirrorDir == "" {
+		return DefaultProviderMirrorDir
+	}
+	return p.MirrorDir
+}
+
+// initArgs - הארגומנטים של terraform init. -upgrade רק כשהתבקש; כשיש lock file הוא
+// לא משתנה (-lockfile=readonly), כך שגרסאות ה-providers זהות בכל הרצה
+func initArgs(config TFConfig) []string {
+	args := []string{"init", "-input=false"}
+	if config.Providers.Upgrade {
+		args = append(args, "-upgrade")
+	} else if _, err := os.Stat(filepath.Join(config.Dir, lockFileName)); err == nil {
+		args = append(args, "-lockfile=readonly")
+	}
+	if config.BackendVarsFile != "" {
+		args = append(args, fmt.Sprintf("-backend-config=%s", config.BackendVarsFile))
+	}
+	return args
+}
+
+// initEnv מכין את משתני הסביבה של init: plugin cache משותף, ובמצב offline קובץ
+// CLI config שמפנה רק ל-mirror המקומי. cleanup מוחק את הקבצים הזמניים
+func initEnv(log *zerolog.Logger, config TFConfig) (env []string, cleanup func(), err error) {
+	cleanup = func() {}
+
+	if dir := config.Providers.cacheDir(); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, cleanup, fmt.Errorf("failed to create plugin cache dir: %w", err)
+		}
+		env = append(env, "TF_PLUGIN_CACHE_DIR="+dir)
+		log.Debug().Str("dir", dir).Msg("Using shared Terraform plugin cache")
+	}
+
+	if !config.Providers.Offline {
+		return env, cleanup, nil
+	}
+
+	mirror, err := filepath.Abs(config.Providers.mirrorDir())
+	if err != nil {
+		return nil, cleanup, err
+	}
+	if info, err := os.Stat(mirror); err != nil || !info.IsDir() {
+		return nil, cleanup, fmt.Errorf("offline mode: provider mirror %s not found (create it with -cmd providers-mirror)", mirror)
+	}
+
+	// בלי בלוק direct - Terraform לא ינסה להוריד מה-registry
+	cliConfig := fmt.Sprintf("provider_installation {\n  filesystem_mirror {\n    path = %s\n  }\n}\n", strconv.Quote(mirror))
+	f, err := os.CreateTemp("", "terraformrc-*")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to write terraform CLI config: %w", err)
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if _, err := f.WriteString(cliConfig); err != nil {
+		f.Close()
+		return nil, cleanup, fmt.Errorf("failed to write terraform CLI config: %w", err)
+	}
+	f.Close()
+
+	log.Info().Str("mirror", mirror).Msg("📴 Offline mode - installing providers from the local mirror only")
+	return append(env, "TF_CLI_CONFIG_FILE="+f.Name()), cleanup, nil
+}
+
+// MirrorProviders downloads the providers required by the stack in opts into dir, so a
+// later offline run (ProviderSettings.Offline) can init without network access.
+func MirrorProviders(log *zerolog.Logger, opts TerraformOptions, dir string) error {
+	if dir == "" {
+		dir = DefaultProviderMirrorDir
+	}
+	mirror, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("stack", opts.TerraformDir).Str("mirror", mirror).Msg("📦 Mirroring Terraform providers...")
+	if _, err := RunTerraform(log, opts.TerraformDir, "providers", "mirror", mirror); err != nil {
+		return fmt.Errorf("terraform providers mirror failed: %w", err)
+	}
+
+	log.Info().Str("mirror", mirror).Msg("✅ Providers mirrored")
+	return nil
+}
@@ -0,0 +1,203 @@
+package tfUtils
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/rs/zerolog"
+	"google.golang.org/api/iterator"
+)
+
+// DefaultStateBackupDir - תיקיית הגיבויים המקומיים של ה-state לפני destroy
+const DefaultStateBackupDir = "state-backups"
+
+var (
+	// ErrDestroyProtected - destroy נחסם כי הסביבה מוגנת
+	ErrDestroyProtected = errors.New("destroy refused: environment is protected")
+	// ErrDestroyNotConfirmed - מזהה הפרויקט שהוקלד לא תואם
+	ErrDestroyNotConfirmed = errors.New("destroy not confirmed")
+	// ErrRootStatePrefix - בלי prefix ה-state בשורש הבוקט, ולא מוחקים שם אוטומטית
+	ErrRootStatePrefix = errors.New("refusing to delete state objects at the bucket root (backend has no prefix)")
+)
+
+// ExtractBackendPrefix מחלץ את ה-prefix של ה-state מהגדרות ה-backend. backendVarsFile
+// (-backend-config ב-init) גובר על ה-backend block, בדיוק כמו ב-Terraform
+func ExtractBackendPrefix(log *zerolog.Logger, dir, backendVarsFile string) string {
+	extractor := NewTerraformConfigExtractor(log, dir)
+	if backendVarsFile != "" {
+		path := backendVarsFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path) // terraform רץ בתוך dir
+		}
+		if prefix := extractor.extractFromAttributesFile(path, "prefix"); prefix != "" {
+			return prefix
+		}
+	}
+	return extractor.ExtractVariable("prefix", ConfigSourceTfFiles, ConfigSourceBackendFiles)
+}
+
+// readConfirmation מציג שאלה בטרמינל ומחזיר את מה שהוקלד
+func readConfirmation(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// confirmDestroy דורש להקליד את מזהה הפרויקט. confirmed (מ-CLI) מחליף את ההקלדה בהרצה לא אינטראקטיבית.
+// nonInteractive בלי confirmed - נכשלים מיד במקום לחכות ל-stdin
+func confirmDestroy(log *zerolog.Logger, projectID, confirmed string, nonInteractive bool) error {
+	if confirmed == "" && nonInteractive {
+		log.Error().Str("project", projectID).Msg("❌ Destroy needs a confirmation and this run is not interactive")
+		return fmt.Errorf("%w: non-interactive run without a confirmed project ID", ErrDestroyNotConfirmed)
+	}
+	if confirmed == "" {
+		log.Warn().Str("project", projectID).Msg("🔥 About to DESTROY all resources of this stack")
+		line, err := readConfirmation(fmt.Sprintf("Type the project ID (%s) to confirm destroy: ", projectID))
+		if err != nil {
+			return fmt.Errorf("%w: no confirmation input: %v", ErrDestroyNotConfirmed, err)
+		}
+		confirmed = line
+	}
+
+	if confirmed != projectID {
+		log.Error().Str("project", projectID).Str("typed", confirmed).Msg("❌ Destroy confirmation does not match project ID")
+		return fmt.Errorf("%w: typed %q, expected %q", ErrDestroyNotConfirmed, confirmed, projectID)
+	}
+
+	log.Info().Str("project", projectID).Msg("✅ Destroy confirmed")
+	return nil
+}
+
+// stateObjectInPrefix - האם האובייקט שייך ל-stack הנוכחי. ה-backend שומר <prefix>/<workspace>.tfstate,
+// לכן רק אובייקטים ישירות תחת ה-prefix - לא stack אחר שה-prefix שלו מקונן (<prefix>/sub/...).
+// בלי prefix, Terraform שומר את ה-state בשורש הבוקט - לכן רק קבצים בשורש נחשבים שלנו
+func stateObjectInPrefix(name, prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		var ok bool
+		if name, ok = strings.CutPrefix(name, prefix+"/"); !ok {
+			return false
+		}
+	}
+	return name != "" && !strings.Contains(name, "/")
+}
+
+// listStateObjects מחזיר את שמות האובייקטים של ה-stack הנוכחי בלבד
+func listStateObjects(ctx context.Context, client *storage.Client, bucketName, prefix string) ([]string, error) {
+	query := &storage.Query{}
+	if p := strings.Trim(prefix, "/"); p != "" {
+		query.Prefix = p + "/"
+	}
+
+	var names []string
+	it := client.Bucket(bucketName).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects in bucket: %v", err)
+		}
+		if stateObjectInPrefix(attrs.Name, prefix) {
+			names = append(names, attrs.Name)
+		}
+	}
+	return names, nil
+}
+
+// backupStatePrefix מוריד את כל אובייקטי ה-state של ה-prefix לארכיון tar.gz מקומי
+func backupStatePrefix(ctx context.Context, log *zerolog.Logger, client *storage.Client, bucketName, prefix, backupDir string) (string, error) {
+	names, err := listStateObjects(ctx, client, bucketName, prefix)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	label := strings.NewReplacer("/", "_").Replace(strings.Trim(prefix, "/"))
+	if label == "" {
+		label = "root"
+	}
+	path := filepath.Join(backupDir, fmt.Sprintf("%s-%s-%s.tar.gz", bucketName, label, time.Now().UTC().Format("20060102T150405Z")))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		if err := addObjectToArchive(ctx, tw, client.Bucket(bucketName).Object(name)); err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+		log.Debug().Str("object", name).Msg("Backed up state object")
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	log.Info().Str("archive", path).Int("objects", len(names)).Msg("💾 State backed up locally")
+	return path, nil
+}
+
+func addObjectToArchive(ctx context.Context, tw *tar.Writer, obj *storage.ObjectHandle) error {
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    obj.ObjectName(),
+		Mode:    0600,
+		Size:    r.Attrs.Size,
+		ModTime: r.Attrs.LastModified,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// deleteStatePrefix מוחק רק את אובייקטי ה-state של ה-stack הנוכחי - stacks אחרים
+// באותו בוקט לא נוגעים. הבוקט עצמו נשאר (עם versioning הגרסאות הישנות נשמרות)
+func deleteStatePrefix(ctx context.Context, log *zerolog.Logger, client *storage.Client, bucketName, prefix string) (int, error) {
+	if strings.Trim(prefix, "/") == "" {
+		return 0, ErrRootStatePrefix
+	}
+	names, err := listStateObjects(ctx, client, bucketName, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, name := range names {
+		if err := client.Bucket(bucketName).Object(name).Delete(ctx); err != nil {
+			return i, fmt.Errorf("failed to delete object %s: %v", name, err)
+		}
+		log.Debug().Str("object", name).Msg("Deleted state object")
+	}
+	return len(names), nil
+}
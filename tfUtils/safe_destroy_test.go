@@ -0,0 +1,103 @@
+package tfUtils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestStateObjectInPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   bool
+	}{
+		{"terraform/state/default.tfstate", "terraform/state", true},
+		{"terraform/state/staging.tflock", "/terraform/state/", true},
+		{"terraform/state/sub/default.tfstate", "terraform/state", false}, // stack מקונן
+		{"terraform/state-network/default.tfstate", "terraform/state", false},
+		{"terraform/state/", "terraform/state", false},
+		{"default.tfstate", "", true},
+		{"terraform/state/default.tfstate", "", false},
+	}
+	for _, tt := range tests {
+		if got := stateObjectInPrefix(tt.name, tt.prefix); got != tt.want {
+			t.Errorf("stateObjectInPrefix(%q, %q) = %v, want %v", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteStateRefusesBucketRoot(t *testing.T) {
+	log := zerolog.Nop()
+	ctx := context.Background()
+	// הבדיקה קודמת לכל גישה לבוקט - client לא נדרש
+	for _, prefix := range []string{"", "/"} {
+		if _, err := deleteStatePrefix(ctx, &log, nil, "bucket", prefix); !errors.Is(err, ErrRootStatePrefix) {
+			t.Errorf("deleteStatePrefix(prefix %q) error = %v, want ErrRootStatePrefix", prefix, err)
+		}
+		if _, err := deleteWorkspaceState(ctx, &log, nil, "bucket", prefix, "staging"); !errors.Is(err, ErrRootStatePrefix) {
+			t.Errorf("deleteWorkspaceState(prefix %q) error = %v, want ErrRootStatePrefix", prefix, err)
+		}
+	}
+}
+
+func TestExtractBackendPrefixUsesBackendVarsFile(t *testing.T) {
+	log := zerolog.Nop()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("backend.tfvars", "bucket = \"b\"\nprefix = \"from-default\"\n")
+	write("prod.tfbackend", "bucket = \"b\"\nprefix = \"from-vars-file\"\n")
+
+	if got := ExtractBackendPrefix(&log, dir, ""); got != "from-default" {
+		t.Errorf("ExtractBackendPrefix() without vars file = %q, want from-default", got)
+	}
+	if got := ExtractBackendPrefix(&log, dir, "prod.tfbackend"); got != "from-vars-file" {
+		t.Errorf("ExtractBackendPrefix() with relative vars file = %q, want from-vars-file", got)
+	}
+	if got := ExtractBackendPrefix(&log, dir, filepath.Join(dir, "prod.tfbackend")); got != "from-vars-file" {
+		t.Errorf("ExtractBackendPrefix() with absolute vars file = %q, want from-vars-file", got)
+	}
+	if got := ExtractBackendPrefix(&log, dir, "missing.tfbackend"); got != "from-default" {
+		t.Errorf("ExtractBackendPrefix() with missing vars file = %q, want from-default", got)
+	}
+}
+
+// stdinWith מחליף את os.Stdin בקלט מוכן - אם הקוד קורא ממנו, האישור "מצליח" והבדיקה נכשלת
+func stdinWith(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	original := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = original
+		r.Close()
+	})
+}
+
+func TestConfirmDestroyNonInteractive(t *testing.T) {
+	log := zerolog.Nop()
+	stdinWith(t, "my-project\n")
+
+	if err := confirmDestroy(&log, "my-project", "", true); !errors.Is(err, ErrDestroyNotConfirmed) {
+		t.Errorf("confirmDestroy(non-interactive, no confirmation) error = %v, want ErrDestroyNotConfirmed", err)
+	}
+	if err := confirmDestroy(&log, "my-project", "my-project", true); err != nil {
+		t.Errorf("confirmDestroy(non-interactive, confirmed) error = %v", err)
+	}
+}
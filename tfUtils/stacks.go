@@ -0,0 +1,287 @@
+package tfUtils
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"DevOps/logger"
+
+	"github.com/rs/zerolog"
+)
+
+// Stack הוא תיקיית Terraform אחת (network / registry / app) עם state משלה
+type Stack struct {
+	Name            string
+	Dir             string
+	VarFile         string
+	BackendVarsFile string
+	DependsOn       []string
+
+	// Inputs - משתנה ב-stack הזה -> "stack.output" של stack שהוא תלוי בו
+	Inputs map[string]string
+}
+
+// OrderStacks ממיין את ה-stacks לפי התלויות (topological sort). סדר ההגדרה נשמר
+// בין stacks בלתי תלויים. reverse מחזיר את הסדר ההפוך - ל-destroy
+func OrderStacks(stacks []Stack, reverse bool) ([]Stack, error) {
+	byName := make(map[string]Stack, len(stacks))
+	for _, s := range stacks {
+		if _, dup := byName[s.Name]; dup {
+			return nil, fmt.Errorf("duplicate stack %q", s.Name)
+		}
+		byName[s.Name] = s
+	}
+
+	for _, s := range stacks {
+		for _, dep := range s.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("stack %q depends on unknown stack %q", s.Name, dep)
+			}
+		}
+		for variable, ref := range s.Inputs {
+			from, _, ok := strings.Cut(ref, ".")
+			if !ok || !dependsOn(s, from) {
+				return nil, fmt.Errorf("stack %q input %q must reference an output of a dependency (got %q)", s.Name, variable, ref)
+			}
+		}
+	}
+
+	var ordered []Stack
+	done := map[string]bool{}
+	for len(ordered) < len(stacks) {
+		progressed := false
+		for _, s := range stacks {
+			if done[s.Name] || !depsDone(s, done) {
+				continue
+			}
+			ordered = append(ordered, s)
+			done[s.Name] = true
+			progressed = true
+		}
+		if !progressed {
+			var pending []string
+			for _, s := range stacks {
+				if !done[s.Name] {
+					pending = append(pending, s.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between stacks: %s", strings.Join(pending, ", "))
+		}
+	}
+
+	if reverse {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	return ordered, nil
+}
+
+func dependsOn(s Stack, name string) bool {
+	for _, dep := range s.DependsOn {
+		if dep == name {
+			return true
+		}
+	}
+	return false
+}
+
+func depsDone(s Stack, done map[string]bool) bool {
+	for _, dep := range s.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// Options - האופציות של ה-stack, על בסיס האופציות של הסביבה
+func (s Stack) Options(opts TerraformOptions) TerraformOptions {
+	stackOpts := opts
+	stackOpts.TerraformDir = s.Dir
+	if s.VarFile != "" {
+		stackOpts.VarFile = s.VarFile
+	}
+	if s.BackendVarsFile != "" {
+		stackOpts.BackendVarsFile = s.BackendVarsFile
+	}
+	// לכל stack prefix משלו, כדי ש-destroy של אחד לא ימחק את ה-state של אחר
+	stackOpts.StatePrefix = "terraform/" + s.Name
+	stackOpts.Vars = maps.Clone(opts.Vars)
+	return stackOpts
+}
+
+// StackOptions מחזיר את האופציות של כל stack לפי סדר התלויות (למשל לבדיקת drift או snapshots)
+func StackOptions(opts TerraformOptions, stacks []Stack, reverse bool) ([]TerraformOptions, error) {
+	ordered, err := OrderStacks(stacks, reverse)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]TerraformOptions, 0, len(ordered))
+	for _, s := range ordered {
+		result = append(result, s.Options(opts))
+	}
+	return result, nil
+}
+
+// stackStatePrefix - ה-prefix שבו ה-stack שומר את ה-state: מה-backend אם הוגדר,
+// אחרת זה שקבצי ברירת המחדל יכתבו (StatePrefix)
+func stackStatePrefix(log *zerolog.Logger, opts TerraformOptions) string {
+	if prefix := ExtractBackendPrefix(log, opts.TerraformDir, opts.BackendVarsFile); prefix != "" {
+		return strings.Trim(prefix, "/")
+	}
+	if opts.StatePrefix != "" {
+		return strings.Trim(opts.StatePrefix, "/")
+	}
+	return "terraform/state"
+}
+
+// ValidateStackPrefixes checks that every stack stores its state under its own prefix.
+// Two stacks sharing a prefix would overwrite each other's state, and destroying one
+// would delete the other's.
+func ValidateStackPrefixes(log *zerolog.Logger, opts TerraformOptions, stacks []Stack) error {
+	owner := map[string]string{}
+	for _, s := range stacks {
+		prefix := stackStatePrefix(log, s.Options(opts))
+		if other, dup := owner[prefix]; dup {
+			return fmt.Errorf("stacks %q and %q use the same state prefix %q", other, s.Name, prefix)
+		}
+		owner[prefix] = s.Name
+	}
+	return nil
+}
+
+// readStackOutputs מריץ init וקורא את ה-outputs הנוכחיים של ה-stack (בלי apply)
+func readStackOutputs(log *zerolog.Logger, opts TerraformOptions, s Stack) (Outputs, error) {
+	if err := Init(log, s.Options(opts).tfConfig()); err != nil {
+		return nil, err
+	}
+	return ReadOutputs(log, s.Dir)
+}
+
+// ResolvedStackOptions is StackOptions for runs that do not apply (drift, snapshots):
+// each stack's Inputs are filled from the current outputs of the stacks it depends on.
+func ResolvedStackOptions(log *zerolog.Logger, opts TerraformOptions, stacks []Stack) ([]TerraformOptions, error) {
+	ordered, err := OrderStacks(stacks, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// רק stacks שמישהו תלוי בהם צריכים init ו-output
+	needed := map[string]bool{}
+	for _, s := range ordered {
+		for _, dep := range s.DependsOn {
+			needed[dep] = true
+		}
+	}
+
+	outputs := map[string]Outputs{}
+	result := make([]TerraformOptions, 0, len(ordered))
+	for _, s := range ordered {
+		stackOpts := s.Options(opts)
+		if stackOpts.Inputs, err = resolveInputs(s, outputs); err != nil {
+			return nil, err
+		}
+		result = append(result, stackOpts)
+
+		if needed[s.Name] {
+			values, err := readStackOutputs(log, opts, s)
+			if err != nil {
+				return nil, fmt.Errorf("stack %s: failed to read outputs: %w", s.Name, err)
+			}
+			outputs[s.Name] = values
+		}
+	}
+	return result, nil
+}
+
+// resolveInputs מחזיר את ה-outputs של ה-stacks שכבר רצו לפי שם המשתנה ב-stack הזה.
+// ה-type נשמר, וערכים רגישים נרשמים ב-logger כדי שיוסתרו בכל שורת לוג
+func resolveInputs(s Stack, outputs map[string]Outputs) (Outputs, error) {
+	inputs := Outputs{}
+	for variable, ref := range s.Inputs {
+		from, output, _ := strings.Cut(ref, ".")
+		value, ok := outputs[from][output]
+		if !ok {
+			return nil, fmt.Errorf("stack %q: output %q of stack %q is not available", s.Name, output, from)
+		}
+		if value.Sensitive {
+			logger.RegisterSecret(value.VarValue())
+		}
+		inputs[variable] = value
+	}
+	return inputs, nil
+}
+
+// ExecuteStacksWorkflow runs ExecuteTerraformWorkflow on every stack in dependency order
+// (reverse order for destroy), passing outputs of earlier stacks into later stacks' variables.
+// The outputs of every applied stack are returned by stack name.
+func ExecuteStacksWorkflow(log *zerolog.Logger, opts TerraformOptions, stacks []Stack) (map[string]Outputs, error) {
+	ordered, err := OrderStacks(stacks, opts.Destroy)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Invalid stack configuration")
+		return nil, err
+	}
+
+	names := make([]string, 0, len(ordered))
+	for _, s := range ordered {
+		names = append(names, s.Name)
+	}
+	log.Info().Strs("order", names).Bool("destroy", opts.Destroy).Msg("🧱 Running Terraform stacks")
+
+	// destroy: מגנים ומאשרים פעם אחת לכל הסביבה, לא לכל stack
+	if opts.Destroy {
+		if opts.Protected {
+			log.Error().Str("project", opts.ProjectID).Msg("🛑 Destroy refused - environment is protected")
+			return nil, ErrDestroyProtected
+		}
+		if opts.WorkspaceProtected() {
+			log.Error().Str("workspace", workspaceName(opts.Workspace)).Msg("🛑 Destroy refused - workspace is protected")
+			return nil, ErrWorkspaceProtected
+		}
+		if err := confirmDestroy(log, opts.ProjectID, opts.ConfirmProjectID, opts.NonInteractive); err != nil {
+			return nil, err
+		}
+		opts.ConfirmProjectID = opts.ProjectID
+	}
+
+	outputs := map[string]Outputs{}
+
+	// ב-destroy ה-stacks שמהם מגיעים ה-inputs עדיין קיימים - קוראים את ה-outputs שלהם מראש
+	if opts.Destroy {
+		forward, _ := OrderStacks(stacks, false)
+		for _, s := range forward {
+			values, err := readStackOutputs(log, opts, s)
+			if err != nil {
+				log.Warn().Err(err).Str("stack", s.Name).Msg("⚠️ Could not read stack outputs before destroy")
+				continue
+			}
+			outputs[s.Name] = values
+		}
+	}
+
+	for _, s := range ordered {
+		stackOpts := s.Options(opts)
+		if stackOpts.Inputs, err = resolveInputs(s, outputs); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to resolve stack inputs")
+			return nil, err
+		}
+
+		log.Info().Str("stack", s.Name).Str("dir", s.Dir).Msg("🧱 Running stack")
+		values, err := ExecuteTerraformWorkflow(log, stackOpts)
+		if err != nil {
+			log.Error().Err(err).Str("stack", s.Name).Msg("❌ Stack failed - stopping before dependent stacks")
+			return nil, fmt.Errorf("stack %s: %w", s.Name, err)
+		}
+		if !opts.Destroy {
+			outputs[s.Name] = values
+		}
+	}
+
+	log.Info().Int("stacks", len(ordered)).Msg("✨ All Terraform stacks completed successfully")
+	if opts.Destroy {
+		return nil, nil
+	}
+	return outputs, nil
+}
@@ -0,0 +1,112 @@
+package tfUtils
+
+import (
+	"maps"
+
+	"cloud.google.com/go/storage"
+	"github.com/rs/zerolog"
+)
+
+// ברירות מחדל לשמירת גרסאות ישנות של ה-state
+const (
+	defaultNoncurrentVersionsToKeep = 10
+	defaultNoncurrentDays           = 90
+)
+
+// StateBucketSettings - ההגנות של בוקט ה-state של Terraform
+type StateBucketSettings struct {
+	Location string            // ברירת מחדל: האזור של הסביבה
+	KMSKey   string            // CMEK אופציונלי: projects/P/locations/L/keyRings/R/cryptoKeys/K
+	Labels   map[string]string // מתווספות ל-labels הקבועות
+
+	// גרסה ישנה נמחקת רק כשיש לה לפחות NoncurrentVersionsToKeep גרסאות חדשות יותר
+	// וגם עברו NoncurrentDays ימים מאז שהוחלפה
+	NoncurrentVersionsToKeep int64
+	NoncurrentDays           int64
+}
+
+// stateBucketLabels - labels קבועות לכל בוקט state, ועליהן ה-labels מההגדרות
+func stateBucketLabels(settings StateBucketSettings) map[string]string {
+	labels := map[string]string{
+		"managed-by": "devops",
+		"purpose":    "terraform-state",
+	}
+	maps.Copy(labels, settings.Labels)
+	return labels
+}
+
+// stateBucketAttrs בונה את הגדרות היצירה של בוקט ה-state
+func stateBucketAttrs(settings StateBucketSettings) *storage.BucketAttrs {
+	keep := settings.NoncurrentVersionsToKeep
+	if keep == 0 {
+		keep = defaultNoncurrentVersionsToKeep
+	}
+	days := settings.NoncurrentDays
+	if days == 0 {
+		days = defaultNoncurrentDays
+	}
+
+	attrs := &storage.BucketAttrs{
+		Location:                 settings.Location,
+		VersioningEnabled:        true,
+		UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true},
+		PublicAccessPrevention:   storage.PublicAccessPreventionEnforced,
+		Labels:                   stateBucketLabels(settings),
+		Lifecycle: storage.Lifecycle{
+			Rules: []storage.LifecycleRule{{
+				Action: storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{
+					Liveness:                storage.Archived,
+					NumNewerVersions:        keep,
+					DaysSinceNoncurrentTime: days,
+				},
+			}},
+		},
+	}
+	if settings.KMSKey != "" {
+		attrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: settings.KMSKey}
+	}
+	return attrs
+}
+
+// auditStateBucket מחזיר את רשימת ההגנות שחסרות בבוקט state קיים
+func auditStateBucket(attrs *storage.BucketAttrs, settings StateBucketSettings) []string {
+	var missing []string
+	if !attrs.VersioningEnabled {
+		missing = append(missing, "object versioning")
+	}
+	if !attrs.UniformBucketLevelAccess.Enabled {
+		missing = append(missing, "uniform bucket-level access")
+	}
+	if attrs.PublicAccessPrevention != storage.PublicAccessPreventionEnforced {
+		missing = append(missing, "public access prevention")
+	}
+	if settings.KMSKey != "" && (attrs.Encryption == nil || attrs.Encryption.DefaultKMSKeyName != settings.KMSKey) {
+		missing = append(missing, "CMEK "+settings.KMSKey)
+	}
+
+	hasNoncurrentRule := false
+	for _, rule := range attrs.Lifecycle.Rules {
+		if rule.Action.Type == storage.DeleteAction &&
+			(rule.Condition.Liveness == storage.Archived || rule.Condition.NumNewerVersions > 0 || rule.Condition.DaysSinceNoncurrentTime > 0) {
+			hasNoncurrentRule = true
+		}
+	}
+	if !hasNoncurrentRule {
+		missing = append(missing, "noncurrent version lifecycle rule")
+	}
+	return missing
+}
+
+// logStateBucketAudit מזהיר על כל הגנה חסרה בבוקט state קיים (לא משנים בוקט קיים אוטומטית)
+func logStateBucketAudit(log *zerolog.Logger, bucketName string, missing []string) {
+	for _, m := range missing {
+		log.Warn().Str("bucket", bucketName).Str("missing", m).Msg("⚠️ Existing state bucket is missing a protection")
+	}
+	if len(missing) > 0 {
+		log.Warn().Str("bucket", bucketName).Int("missing", len(missing)).
+			Msg("🛡️ State bucket is not hardened - consider enabling the protections above")
+	} else {
+		log.Info().Str("bucket", bucketName).Msg("🛡️ State bucket protections verified")
+	}
+}
@@ -0,0 +1,242 @@
+//go:build integration
+
+// בדיקות אינטגרציה ללוגיקת בוקט ה-state מול אמולטור GCS מקומי (fake-gcs-server):
+//
+//	docker run -d --name fake-gcs -p 4443:4443 fsouza/fake-gcs-server -scheme http
+//	STORAGE_EMULATOR_HOST=localhost:4443 go test -tags integration ./tfUtils/...
+package tfUtils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/rs/zerolog"
+)
+
+const testProjectID = "test-project"
+
+var testBucketSettings = StateBucketSettings{
+	Location: "me-west1",
+	Labels:   map[string]string{"environment": "test"},
+}
+
+// newEmulatorClient מחזיר client מול האמולטור, או מדלג אם STORAGE_EMULATOR_HOST לא מוגדר
+func newEmulatorClient(t *testing.T) (context.Context, *storage.Client) {
+	t.Helper()
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		t.Skip("STORAGE_EMULATOR_HOST is not set - start fake-gcs-server to run the integration tests")
+	}
+
+	ctx := context.Background()
+	client, err := NewStorageClient(ctx)
+	if err != nil {
+		t.Fatalf("failed to create storage client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return ctx, client
+}
+
+// uniqueBucketName - שם ייחודי לכל בדיקה כדי שהבדיקות לא יתנגשו זו בזו
+func uniqueBucketName() string {
+	return fmt.Sprintf("tfstate-it-%d", time.Now().UnixNano())
+}
+
+func testLogger() *zerolog.Logger {
+	log := zerolog.Nop()
+	return &log
+}
+
+func TestEnsureGCSBucketCreatesMissingBucket(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	if err := ensureGCSBucket(ctx, testLogger(), client, testProjectID, name, testBucketSettings); err != nil {
+		t.Fatalf("ensureGCSBucket() error = %v", err)
+	}
+
+	attrs, err := client.Bucket(name).Attrs(ctx)
+	if err != nil {
+		t.Fatalf("bucket %s was not created: %v", name, err)
+	}
+	if !attrs.VersioningEnabled {
+		t.Errorf("versioning is not enabled on the new state bucket")
+	}
+	if got := attrs.Labels["purpose"]; got != "terraform-state" {
+		t.Errorf("label purpose = %q, want terraform-state", got)
+	}
+	if got := attrs.Labels["environment"]; got != "test" {
+		t.Errorf("label environment = %q, want test", got)
+	}
+}
+
+func TestAuditStateBucketReportsMissingProtections(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	// בוקט "ישן" שנוצר בלי הגנות
+	if err := client.Bucket(name).Create(ctx, testProjectID, &storage.BucketAttrs{Location: "me-west1"}); err != nil {
+		t.Fatalf("failed to pre-create bucket: %v", err)
+	}
+	attrs, err := client.Bucket(name).Attrs(ctx)
+	if err != nil {
+		t.Fatalf("failed to read bucket attrs: %v", err)
+	}
+
+	missing := auditStateBucket(attrs, testBucketSettings)
+	want := map[string]bool{"object versioning": true, "noncurrent version lifecycle rule": true}
+	for _, m := range missing {
+		delete(want, m)
+	}
+	if len(want) > 0 {
+		t.Fatalf("auditStateBucket() = %v, expected it to report %v", missing, want)
+	}
+}
+
+func TestEnsureGCSBucketAlreadyExists(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	if err := client.Bucket(name).Create(ctx, testProjectID, nil); err != nil {
+		t.Fatalf("failed to pre-create bucket: %v", err)
+	}
+	writeObject(t, ctx, client, name, "terraform/state/default.tfstate")
+
+	if err := ensureGCSBucket(ctx, testLogger(), client, testProjectID, name, testBucketSettings); err != nil {
+		t.Fatalf("ensureGCSBucket() on existing bucket error = %v", err)
+	}
+
+	// הבוקט הקיים והתוכן שלו לא נגעו
+	if _, err := client.Bucket(name).Object("terraform/state/default.tfstate").Attrs(ctx); err != nil {
+		t.Fatalf("existing state object is gone: %v", err)
+	}
+}
+
+func TestCreateGCSBucketNameConflict(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	// הבוקט כבר קיים (למשל בפרויקט אחר) - יצירה שנייה חייבת להיכשל כהתנגשות שם
+	if err := client.Bucket(name).Create(ctx, "someone-elses-project", nil); err != nil {
+		t.Fatalf("failed to pre-create bucket: %v", err)
+	}
+
+	err := createGCSBucket(ctx, testLogger(), client, testProjectID, name, testBucketSettings)
+	if !errors.Is(err, ErrBucketNameConflict) {
+		t.Fatalf("createGCSBucket() error = %v, want ErrBucketNameConflict", err)
+	}
+}
+
+func TestDeleteGCSBucketWithObjects(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+
+	if err := client.Bucket(name).Create(ctx, testProjectID, nil); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+	for _, obj := range []string{
+		"terraform/state/default.tfstate",
+		"terraform/state/default.tflock",
+		"other/prefix/default.tfstate",
+	} {
+		writeObject(t, ctx, client, name, obj)
+	}
+
+	if err := deleteGCSBucket(ctx, testLogger(), client, name); err != nil {
+		t.Fatalf("deleteGCSBucket() error = %v", err)
+	}
+
+	if _, err := client.Bucket(name).Attrs(ctx); !errors.Is(err, storage.ErrBucketNotExist) {
+		t.Fatalf("bucket still exists after delete (Attrs error = %v)", err)
+	}
+}
+
+func writeObject(t *testing.T, ctx context.Context, client *storage.Client, bucket, name string) {
+	t.Helper()
+	w := client.Bucket(bucket).Object(name).NewWriter(ctx)
+	if _, err := w.Write([]byte(`{"version": 4}`)); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer for %s: %v", name, err)
+	}
+}
+
+func TestDeleteStatePrefixKeepsOtherStacks(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	if err := client.Bucket(name).Create(ctx, testProjectID, nil); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+	ours := []string{"terraform/state/default.tfstate", "terraform/state/staging.tfstate"}
+	others := []string{"terraform/state-network/default.tfstate", "terraform/state/sub/default.tfstate", "other/prefix/default.tfstate", "default.tfstate"}
+	for _, obj := range append(append([]string{}, ours...), others...) {
+		writeObject(t, ctx, client, name, obj)
+	}
+
+	archive, err := backupStatePrefix(ctx, testLogger(), client, name, "terraform/state", t.TempDir())
+	if err != nil {
+		t.Fatalf("backupStatePrefix() error = %v", err)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() == 0 {
+		t.Fatalf("backup archive %s missing or empty (err = %v)", archive, err)
+	}
+
+	deleted, err := deleteStatePrefix(ctx, testLogger(), client, name, "terraform/state")
+	if err != nil {
+		t.Fatalf("deleteStatePrefix() error = %v", err)
+	}
+	if deleted != len(ours) {
+		t.Errorf("deleteStatePrefix() deleted %d objects, want %d", deleted, len(ours))
+	}
+
+	for _, obj := range ours {
+		if _, err := client.Bucket(name).Object(obj).Attrs(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("object %s of the destroyed stack still exists (err = %v)", obj, err)
+		}
+	}
+	for _, obj := range others {
+		if _, err := client.Bucket(name).Object(obj).Attrs(ctx); err != nil {
+			t.Errorf("object %s of another stack was deleted: %v", obj, err)
+		}
+	}
+}
+
+func TestDeleteWorkspaceStateKeepsOtherWorkspaces(t *testing.T) {
+	ctx, client := newEmulatorClient(t)
+	name := uniqueBucketName()
+	t.Cleanup(func() { deleteGCSBucket(ctx, testLogger(), client, name) })
+
+	if err := client.Bucket(name).Create(ctx, testProjectID, nil); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+	ours := []string{"terraform/state/staging.tfstate", "terraform/state/staging.tflock"}
+	others := []string{"terraform/state/default.tfstate", "terraform/state/prod.tfstate", "terraform/other/staging.tfstate"}
+	for _, obj := range append(append([]string{}, ours...), others...) {
+		writeObject(t, ctx, client, name, obj)
+	}
+
+	deleted, err := deleteWorkspaceState(ctx, testLogger(), client, name, "terraform/state", "staging")
+	if err != nil {
+		t.Fatalf("deleteWorkspaceState() error = %v", err)
+	}
+	if deleted != len(ours) {
+		t.Errorf("deleteWorkspaceState() deleted %d objects, want %d", deleted, len(ours))
+	}
+
+	for _, obj := range others {
+		if _, err := client.Bucket(name).Object(obj).Attrs(ctx); err != nil {
+			t.Errorf("object %s of another workspace was deleted: %v", obj, err)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package tfUtils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"DevOps/history"
+
+	"cloud.google.com/go/storage"
+	"github.com/rs/zerolog"
+	"google.golang.org/api/iterator"
+)
+
+// DefaultSnapshotDir - תיקיית ה-snapshots המקומית של ה-state
+const DefaultSnapshotDir = "state-snapshots"
+
+// bucketSnapshotRoot - ה-snapshots בבוקט נשמרים מחוץ ל-prefix של ה-stack,
+// כדי ש-destroy (שמוחק רק את ה-prefix) לא ימחק גם אותם
+const bucketSnapshotRoot = "snapshots"
+
+// מיקום ה-snapshot
+const (
+	SnapshotLocal  = "local"
+	SnapshotBucket = "gcs"
+)
+
+var (
+	// ErrSnapshotNotFound - אין snapshot עם המזהה המבוקש
+	ErrSnapshotNotFound = errors.New("state snapshot not found")
+	// ErrSnapshotLineageMismatch - ה-snapshot שייך ל-state אחר
+	ErrSnapshotLineageMismatch = errors.New("state snapshot lineage does not match the current state")
+	// ErrSnapshotSerialBehind - ה-snapshot לא חדש מה-state הנוכחי; שחזור יבטל את השינויים שאחריו
+	ErrSnapshotSerialBehind = errors.New("state snapshot is not newer than the current state: restoring it discards later changes (use force to confirm)")
+)
+
+// SnapshotSettings - איפה לשמור snapshots של ה-state לפני apply / destroy
+type SnapshotSettings struct {
+	Disabled bool
+	LocalDir string // ברירת מחדל: DefaultSnapshotDir. "-" = בלי עותק מקומי
+	InBucket bool   // עותק נוסף בבוקט ה-state, תחת snapshots/<prefix>/
+}
+
+// Snapshot is one saved copy of the remote Terraform state.
+type Snapshot struct {
+	ID               string    `json:"id"`
+	Serial           int64     `json:"serial"`
+	Lineage          string    `json:"lineage"`
+	TerraformVersion string    `json:"terraform_version"`
+	Created          time.Time `json:"created"`
+	Reason           string    `json:"reason"`
+	Location         string    `json:"location"`
+	Path             string    `json:"path"`
+}
+
+// stateHeader - השדות של קובץ state שה-snapshots צריכים
+type stateHeader struct {
+	Serial           int64  `json:"serial"`
+	Lineage          string `json:"lineage"`
+	TerraformVersion string `json:"terraform_version"`
+}
+
+// stateStack - ה-stack שה-snapshots שייכים אליו: תיקייה, בוקט ו-prefix
+type stateStack struct {
+	config   TFConfig
+	bucket   string
+	prefix   string
+	client   *storage.Client
+	settings SnapshotSettings
+
+	workspace string
+}
+
+// stackLabel - ה-prefix כשם תיקייה ("terraform/state" -> "terraform_state")
+func (s *stateStack) stackLabel() string {
+	label := strings.ReplaceAll(strings.Trim(s.prefix, "/"), "/", "_")
+	if label == "" {
+		label = "root"
+	}
+	// לכל workspace state משלו - וגם snapshots משלו
+	if s.workspace != "" && s.workspace != DefaultWorkspace {
+		label += "@" + s.workspace
+	}
+	return label
+}
+
+func (s *stateStack) localDir() string {
+	dir := s.settings.LocalDir
+	if dir == "" {
+		dir = DefaultSnapshotDir
+	}
+	if dir == "-" {
+		return ""
+	}
+	return filepath.Join(dir, s.bucket, s.stackLabel())
+}
+
+func (s *stateStack) bucketPrefix() string {
+	return bucketSnapshotRoot + "/" + s.stackLabel() + "/"
+}
+
+// pullState מחזיר את ה-state הנוכחי כפי שהוא ב-backend (ריק אם עוד אין state)
+func pullState(log *zerolog.Logger, config TFConfig) ([]byte, *stateHeader, error) {
+	out, err := RunTerraformOutput(log, config.Dir, "state", "pull")
+	if err != nil {
+		return nil, nil, fmt.Errorf("terraform state pull failed: %w", err)
+	}
+	data := []byte(strings.TrimSpace(out))
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+
+	var header stateHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse pulled state: %w", err)
+	}
+	return data, &header, nil
+}
+
+// snapshotState שומר עותק של ה-state הנוכחי (לוקאלית ו/או בבוקט) לפני פעולה
+func snapshotState(ctx context.Context, log *zerolog.Logger, stack *stateStack, reason string) (*Snapshot, error) {
+	data, header, err := pullState(log, stack.config)
+	if err != nil {
+		return nil, err
+	}
+	return saveSnapshot(ctx, log, stack, data, header, reason)
+}
+
+// saveSnapshot שומר state שכבר נמשך (data / header מ-pullState)
+func saveSnapshot(ctx context.Context, log *zerolog.Logger, stack *stateStack, data []byte, header *stateHeader, reason string) (*Snapshot, error) {
+	if header == nil {
+		log.Info().Str("reason", reason).Msg("📸 No remote state yet - nothing to snapshot")
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	snap := &Snapshot{
+		ID:               fmt.Sprintf("%s-serial%d-%s", now.Format("20060102T150405Z"), header.Serial, reason),
+		Serial:           header.Serial,
+		Lineage:          header.Lineage,
+		TerraformVersion: header.TerraformVersion,
+		Created:          now,
+		Reason:           reason,
+	}
+
+	if dir := stack.localDir(); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+		snap.Location, snap.Path = SnapshotLocal, filepath.Join(dir, snap.ID+".tfstate")
+		if err := os.WriteFile(snap.Path, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+
+	if stack.settings.InBucket {
+		name := stack.bucketPrefix() + snap.ID + ".tfstate"
+		w := stack.client.Bucket(stack.bucket).Object(name).NewWriter(ctx)
+		w.ContentType = "application/json"
+		w.Metadata = map[string]string{"reason": reason, "lineage": header.Lineage}
+		if _, err := w.Write(data); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to upload snapshot: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to upload snapshot: %w", err)
+		}
+		if snap.Path == "" {
+			snap.Location, snap.Path = SnapshotBucket, name
+		}
+	}
+
+	log.Info().
+		Str("snapshot", snap.ID).
+		Int64("serial", snap.Serial).
+		Str("lineage", snap.Lineage).
+		Str("location", snap.Location).
+		Msg("📸 Terraform state snapshot saved")
+	return snap, nil
+}
+
+// listSnapshots מחזיר את כל ה-snapshots של ה-stack (מקומיים ובבוקט), מהחדש לישן
+func listSnapshots(ctx context.Context, stack *stateStack) ([]Snapshot, error) {
+	var snaps []Snapshot
+
+	if dir := stack.localDir(); dir != "" {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.tfstate"))
+		for _, path := range files {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			info, _ := os.Stat(path)
+			snaps = append(snaps, newSnapshot(path, SnapshotLocal, data, info.ModTime()))
+		}
+	}
+
+	if stack.settings.InBucket {
+		it := stack.client.Bucket(stack.bucket).Objects(ctx, &storage.Query{Prefix: stack.bucketPrefix()})
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list bucket snapshots: %w", err)
+			}
+			data, err := readObject(ctx, stack.client.Bucket(stack.bucket).Object(attrs.Name))
+			if err != nil {
+				return nil, err
+			}
+			snaps = append(snaps, newSnapshot(attrs.Name, SnapshotBucket, data, attrs.Created))
+		}
+	}
+
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Created.After(snaps[j].Created) })
+	return snaps, nil
+}
+
+// newSnapshot בונה Snapshot מקובץ שמור - המזהה הוא שם הקובץ
+func newSnapshot(path, location string, data []byte, created time.Time) Snapshot {
+	var header stateHeader
+	_ = json.Unmarshal(data, &header)
+
+	id := strings.TrimSuffix(filepath.Base(path), ".tfstate")
+	reason := ""
+	if i := strings.LastIndex(id, "-"); i >= 0 {
+		reason = id[i+1:]
+	}
+	return Snapshot{
+		ID:               id,
+		Serial:           header.Serial,
+		Lineage:          header.Lineage,
+		TerraformVersion: header.TerraformVersion,
+		Created:          created,
+		Reason:           reason,
+		Location:         location,
+		Path:             path,
+	}
+}
+
+func readObject(ctx context.Context, obj *storage.ObjectHandle) ([]byte, error) {
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", obj.ObjectName(), err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// readSnapshot טוען את התוכן של snapshot
+func readSnapshot(ctx context.Context, stack *stateStack, snap Snapshot) ([]byte, error) {
+	if snap.Location == SnapshotBucket {
+		return readObject(ctx, stack.client.Bucket(stack.bucket).Object(snap.Path))
+	}
+	return os.ReadFile(snap.Path)
+}
+
+// restoreSnapshot מחזיר snapshot ל-backend עם terraform state push. בלי force, ה-lineage חייב
+// להיות זהה ל-state הנוכחי וה-serial של ה-snapshot גבוה ממנו - הבדיקה של Terraform עצמו.
+// רק force מקפיץ את ה-serial מעל הנוכחי (ודוחף עם -force) כדי לחזור ל-state ישן יותר
+func restoreSnapshot(ctx context.Context, log *zerolog.Logger, stack *stateStack, id string, force bool) error {
+	snaps, err := listSnapshots(ctx, stack)
+	if err != nil {
+		return err
+	}
+	var snap *Snapshot
+	for i := range snaps {
+		if snaps[i].ID == id {
+			snap = &snaps[i]
+			break
+		}
+	}
+	if snap == nil {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+
+	data, err := readSnapshot(ctx, stack, *snap)
+	if err != nil {
+		return err
+	}
+
+	currentData, current, err := pullState(log, stack.config)
+	if err != nil {
+		return err
+	}
+	serial := snap.Serial
+	if current != nil {
+		if current.Lineage != snap.Lineage {
+			if !force {
+				log.Error().Str("current", current.Lineage).Str("snapshot", snap.Lineage).Msg("❌ Snapshot lineage does not match the current state")
+				return ErrSnapshotLineageMismatch
+			}
+			log.Warn().Str("current", current.Lineage).Str("snapshot", snap.Lineage).Msg("⚠️ Forcing restore of a snapshot from a different lineage")
+		}
+		if current.Serial >= snap.Serial {
+			if !force {
+				log.Error().Int64("current", current.Serial).Int64("snapshot", snap.Serial).Msg("❌ Snapshot serial is not newer than the current state")
+				return ErrSnapshotSerialBehind
+			}
+			log.Warn().Int64("current", current.Serial).Int64("snapshot", snap.Serial).Msg("⚠️ Forcing restore of an older snapshot - later state changes are discarded")
+			serial = current.Serial + 1
+		}
+
+		// snapshot של המצב הנוכחי (מאותו pull) - כדי שאפשר יהיה לבטל את השחזור
+		if _, err := saveSnapshot(ctx, log, stack, currentData, current, "prerestore"); err != nil {
+			return err
+		}
+	}
+
+	// serial חדש רק כשהוקפץ (שומרים את שאר הקובץ כמו שהוא)
+	var state map[string]any
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	state["serial"] = serial
+
+	payload, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(stack.config.Dir, ".restore-*.tfstate")
+	if err != nil {
+		return fmt.Errorf("failed to write restore file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	args := []string{"state", "push"}
+	if force {
+		args = append(args, "-force")
+	}
+	args = append(args, filepath.Base(tmp.Name()))
+	if _, err := RunTerraform(log, stack.config.Dir, args...); err != nil {
+		return fmt.Errorf("terraform state push failed: %w", err)
+	}
+
+	log.Info().Str("snapshot", snap.ID).Int64("serial", serial).Msg("♻️ Terraform state restored from snapshot")
+	return nil
+}
+
+// openStateStack מכין את ה-stack של opts: client, בוקט ו-prefix מה-backend
+func openStateStack(ctx context.Context, log *zerolog.Logger, opts TerraformOptions) (*stateStack, func(), error) {
+	bucket := ExtractBackendBucket(log, opts.TerraformDir)
+	if bucket == "" {
+		return nil, nil, errors.New("no GCS backend bucket configured")
+	}
+	client, err := NewStorageClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	stack := &stateStack{
+		config:    opts.tfConfig(),
+		bucket:    bucket,
+		prefix:    ExtractBackendPrefix(log, opts.TerraformDir, opts.BackendVarsFile),
+		client:    client,
+		settings:  opts.Snapshots,
+		workspace: opts.Workspace,
+	}
+	return stack, func() { client.Close() }, nil
+}
+
+// ListStateSnapshots returns the saved state snapshots of the stack in opts, newest first.
+func ListStateSnapshots(log *zerolog.Logger, opts TerraformOptions) ([]Snapshot, error) {
+	ctx := context.Background()
+	stack, closeFn, err := openStateStack(ctx, log, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	return listSnapshots(ctx, stack)
+}
+
+// RestoreStateSnapshot pushes snapshot id back to the remote state after lineage and serial checks.
+func RestoreStateSnapshot(log *zerolog.Logger, opts TerraformOptions, id string, force bool) (err error) {
+	entry := history.NewEntry("terraform-state-restore")
+	entry.Details["project"] = opts.ProjectID
+	entry.Details["dir"] = opts.TerraformDir
+	entry.Details["snapshot"] = id
+	defer func() {
+		entry.Finish(err)
+		if recErr := history.Record(entry); recErr != nil {
+			log.Warn().Err(recErr).Msg("⚠️ Failed to record state restore in run history")
+		}
+	}()
+
+	ctx := context.Background()
+	stack, closeFn, err := openStateStack(ctx, log, opts)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := Init(log, stack.config); err != nil {
+		return err
+	}
+	return restoreSnapshot(ctx, log, stack, id, force)
+}
@@ -0,0 +1,32 @@
+package tfUtils
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"DevOps/gcpUtils"
+
+	"cloud.google.com/go/storage"
+)
+
+// StorageClientFactory creates the GCS client used for the remote state bucket.
+type StorageClientFactory func(ctx context.Context) (*storage.Client, error)
+
+// NewStorageClient - ה-factory שבו משתמש ה-workflow. אפשר להחליף אותו (למשל בבדיקות)
+var NewStorageClient StorageClientFactory = defaultStorageClient
+
+// defaultStorageClient יוצר client עם ה-credentials של gcpUtils (כולל impersonation).
+// כשמוגדר STORAGE_EMULATOR_HOST הספרייה עצמה מפנה לאמולטור בלי אימות -
+// ולכן לא מעבירים לה credentials שיתנגשו בזה
+func defaultStorageClient(ctx context.Context) (*storage.Client, error) {
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		return storage.NewClient(ctx)
+	}
+
+	clientOpts, err := gcpUtils.ClientOptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve GCP credentials: %w", err)
+	}
+	return storage.NewClient(ctx, clientOpts...)
+}
@@ -0,0 +1,112 @@
+package tfUtils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"DevOps/history"
+
+	"github.com/rs/zerolog"
+)
+
+// WorkflowHistoryKind - סוג הרשומה של הרצות apply / destroy בהיסטוריית ההרצות
+const WorkflowHistoryKind = "terraform-workflow"
+
+// Targeting מצמצם הרצה לחלק מה-stack. ריק = כל ה-stack
+type Targeting struct {
+	Targets     []string `json:"targets,omitempty"`      // -target: רק המשאבים האלה (והתלויות שלהם)
+	Replace     []string `json:"replace,omitempty"`      // -replace: ליצור מחדש את המשאבים האלה
+	RefreshOnly bool     `json:"refresh_only,omitempty"` // -refresh-only: לעדכן את ה-state בלבד, בלי שינויים בתשתית
+	Parallelism int      `json:"parallelism,omitempty"`  // -parallelism (0 = ברירת המחדל של Terraform)
+}
+
+// Partial - הרצה שלא מביאה את כל ה-stack למצב הרצוי (target / refresh-only)
+func (t Targeting) Partial() bool {
+	return len(t.Targets) > 0 || t.RefreshOnly
+}
+
+// Validate בודק צירופים ש-Terraform לא מקבל
+func (t Targeting) Validate(destroy bool) error {
+	if t.Parallelism < 0 {
+		return fmt.Errorf("parallelism must be positive (got %d)", t.Parallelism)
+	}
+	if t.RefreshOnly && len(t.Replace) > 0 {
+		return errors.New("-refresh-only cannot be combined with -replace")
+	}
+	if destroy && (t.RefreshOnly || len(t.Replace) > 0) {
+		return errors.New("-refresh-only and -replace cannot be used with destroy")
+	}
+	for _, addr := range append(append([]string{}, t.Targets...), t.Replace...) {
+		if strings.TrimSpace(addr) == "" || strings.HasPrefix(addr, "-") {
+			return fmt.Errorf("invalid resource address %q", addr)
+		}
+	}
+	return nil
+}
+
+// args - הארגומנטים של plan / apply / destroy לפי ה-targeting
+func (t Targeting) args() []string {
+	var args []string
+	for _, target := range t.Targets {
+		args = append(args, "-target="+target)
+	}
+	for _, addr := range t.Replace {
+		args = append(args, "-replace="+addr)
+	}
+	if t.RefreshOnly {
+		args = append(args, "-refresh-only")
+	}
+	return append(args, t.parallelismArgs()...)
+}
+
+// parallelismArgs - רק -parallelism (apply של plan שמור כבר כולל את ה-targets)
+func (t Targeting) parallelismArgs() []string {
+	if t.Parallelism > 0 {
+		return []string{"-parallelism=" + strconv.Itoa(t.Parallelism)}
+	}
+	return nil
+}
+
+// logScope מדפיס אזהרה כשההרצה חלקית, כמו ש-Terraform עצמו מזהיר על -target
+func (t Targeting) logScope(log *zerolog.Logger) {
+	if len(t.Targets) > 0 {
+		log.Warn().Strs("targets", t.Targets).Msg("🎯 Targeted run - only these resources (and their dependencies) will change")
+	}
+	if len(t.Replace) > 0 {
+		log.Warn().Strs("replace", t.Replace).Msg("♻️ These resources will be replaced")
+	}
+	if t.RefreshOnly {
+		log.Info().Msg("🔄 Refresh-only run - state is updated, infrastructure is not changed")
+	}
+}
+
+// workflowEntry - רשומת ההיסטוריה של הרצת workflow. הרצה חלקית מסומנת partial=true
+func workflowEntry(opts TerraformOptions) history.Entry {
+	entry := history.NewEntry(WorkflowHistoryKind)
+	entry.Details["project"] = opts.ProjectID
+	entry.Details["dir"] = opts.TerraformDir
+	entry.Details["workspace"] = workspaceName(opts.Workspace)
+
+	operation := "apply"
+	switch {
+	case opts.Destroy:
+		operation = "destroy"
+	case opts.Targeting.RefreshOnly:
+		operation = "refresh-only"
+	}
+	entry.Details["operation"] = operation
+	entry.Details["partial"] = strconv.FormatBool(opts.Targeting.Partial())
+
+	if len(opts.Targeting.Targets) > 0 {
+		entry.Details["targets"] = strings.Join(opts.Targeting.Targets, ",")
+	}
+	if len(opts.Targeting.Replace) > 0 {
+		entry.Details["replace"] = strings.Join(opts.Targeting.Replace, ",")
+	}
+	if opts.Targeting.Parallelism > 0 {
+		entry.Details["parallelism"] = strconv.Itoa(opts.Targeting.Parallelism)
+	}
+	return entry
+}
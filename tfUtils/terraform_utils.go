@@ -2,15 +2,19 @@ package tfUtils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 
 
-	"DevOps/gcpUtils" // וודא שהנתיב תואם ל-go.mod שלך
+	"DevOps/history"
 
 	"cloud.google.com/go/storage"
 	"github.com/rs/zerolog"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 )
 
@@ -20,15 +24,76 @@ type TFConfig struct {
     VarFile         string
     BackendVarsFile string
     Vars            map[string]string 
+
+    // כשה-backend השתנה: MigrateState מעתיק את ה-state (אחרי אישור), Reconfigure מתעלם ממנו
+    MigrateState bool
+    Reconfigure  bool
+
+    NonInteractive bool // אין מי שיקליד (הרצה מה-API) - במקום לשאול בטרמינל נכשלים מיד
+
+    Providers ProviderSettings
+
+    Workspace string // workspace שנבחר (ונוצר אם צריך) אחרי init. ריק = default
+
+    Targeting Targeting // -target / -replace / -refresh-only / -parallelism
+
+    VarsViaEnv bool // Vars כ-TF_VAR_ במקום קובץ tfvars זמני (שימו לב: TF_VAR_ לא גובר על VarFile)
+
+    Inputs Outputs // outputs של stacks קודמים לפי שם המשתנה - תמיד בקובץ tfvars זמני, עם ה-type שלהם
 }
 
 // TerraformOptions מגדיר את כל מה שצריך להרצה
 type TerraformOptions struct {
 	ProjectID       string
+	Region          string
 	TerraformDir    string
 	VarFile         string
 	BackendVarsFile string
 	Destroy         bool
+	EstimateCost    bool   // plan + אומדן עלות חודשית לפני ה-apply
+	PricingFile     string // טבלת מחירים חלופית (ברירת מחדל: pricing.json המובנה)
+	StateBucket     StateBucketSettings
+
+	// destroy בטוח
+	Protected        bool   // סביבה מוגנת - destroy נחסם לגמרי
+	ConfirmProjectID string // אישור לא אינטראקטיבי: חייב להיות זהה ל-ProjectID
+	StateBackupDir   string // ברירת מחדל: DefaultStateBackupDir
+
+	NonInteractive bool // הרצה בלי טרמינל (API) - שום אישור לא נקרא מ-stdin
+
+	Snapshots SnapshotSettings
+
+	MigrateState bool // אישור מראש להעתקת state כשה-backend השתנה
+	Reconfigure  bool // כשה-backend השתנה - להתחיל מה-backend החדש בלי להעתיק state
+	Providers    ProviderSettings
+
+	Workspace           string   // ריק = default
+	ProtectedWorkspaces []string // workspaces שחסומים ל-destroy
+
+	Targeting Targeting // הרצה חלקית / החלפת משאבים. ריק = כל ה-stack
+	VarsViaEnv bool     // Vars כ-TF_VAR_ במקום קובץ tfvars זמני
+
+	StatePrefix string            // prefix ה-state בקבצי ברירת המחדל (ברירת מחדל: terraform/state)
+	Vars        map[string]string // משתנים נוספים (-var)
+	Inputs      Outputs           // outputs של stacks קודמים לפי שם המשתנה (ממולא ע"י resolveInputs)
+}
+
+// tfConfig - הגדרות ההרצה של Terraform לפי האופציות
+func (opts TerraformOptions) tfConfig() TFConfig {
+	return TFConfig{
+		Dir:             opts.TerraformDir,
+		VarFile:         opts.VarFile,
+		BackendVarsFile: opts.BackendVarsFile,
+		Vars:            opts.Vars,
+		MigrateState:    opts.MigrateState,
+		Reconfigure:     opts.Reconfigure,
+		NonInteractive:  opts.NonInteractive,
+		Providers:       opts.Providers,
+		Workspace:       opts.Workspace,
+		Targeting:       opts.Targeting,
+		VarsViaEnv:      opts.VarsViaEnv,
+		Inputs:          opts.Inputs,
+	}
 }
 
 // ExtractBackendBucket מחלץ את שם ה-bucket מהגדרות ה-backend
@@ -45,14 +110,11 @@ func ExtractBackendBucket(log *zerolog.Logger, dir string) string {
     return extractor.ExtractVariable("bucket")
 }
 
-func ensureGCSBucket(log *zerolog.Logger, projectID, bucketName string) error {
+// ErrBucketNameConflict - שם הבוקט כבר תפוס (גלובלית ב-GCS, גם בפרויקט של מישהו אחר)
+var ErrBucketNameConflict = errors.New("bucket name is already taken")
+
+func ensureGCSBucket(ctx context.Context, log *zerolog.Logger, client *storage.Client, projectID, bucketName string, settings StateBucketSettings) error {
     log.Info().Str("bucket", bucketName).Str("project", projectID).Msg("🧐 Checking remote state bucket...")
-    ctx := context.Background()
-    client, err := storage.NewClient(ctx)
-    if err != nil {
-        return fmt.Errorf("❌ failed to create GCP client: %w", err)
-    }
-    defer client.Close()
 
     bucket := client.Bucket(bucketName)
     attrs, err := bucket.Attrs(ctx)
@@ -70,28 +132,48 @@ func ensureGCSBucket(log *zerolog.Logger, projectID, bucketName string) error {
         // לעיתים קרובות לא תהיה לך גישה אפילו ל-Attrs (תקבל 403).
         // אם הצלחת לקרוא Attrs אבל אתה לא רואה אותו בפרויקט שלך ב-Console,
         // סימן שהוא שייך לפרויקט אחר שבו יש לך הרשאות.
-        
+
+        logStateBucketAudit(log, bucketName, auditStateBucket(attrs, settings))
         return nil 
     }
 
     // אם קיבלנו שגיאה, נבדוק אם זה בגלל שהוא לא קיים
     log.Warn().Err(err).Str("bucket", bucketName).Msg("🪣 Bucket not found or not accessible, attempting to create...")
 
-    newAttrs := &storage.BucketAttrs{
-        Location: "me-west1",
-    }
+    return createGCSBucket(ctx, log, client, projectID, bucketName, settings)
+}
 
-    if err := bucket.Create(ctx, projectID, newAttrs); err != nil {
-        // כאן תקבל שגיאת "Conflict" (409) אם השם תפוס גלובלית
+// createGCSBucket יוצר את בוקט ה-state. שם תפוס מוחזר כ-ErrBucketNameConflict
+func createGCSBucket(ctx context.Context, log *zerolog.Logger, client *storage.Client, projectID, bucketName string, settings StateBucketSettings) error {
+    newAttrs := stateBucketAttrs(settings)
+
+    if err := client.Bucket(bucketName).Create(ctx, projectID, newAttrs); err != nil {
         log.Error().Err(err).Str("bucket", bucketName).Msg("❌ Failed to create GCS bucket")
-        return fmt.Errorf("conflict: bucket name '%s' might be taken globally: %w", bucketName, err)
+
+        // כאן תקבל שגיאת "Conflict" (409) אם השם תפוס גלובלית
+        var apiErr *googleapi.Error
+        if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+            return fmt.Errorf("%w: '%s': %v", ErrBucketNameConflict, bucketName, err)
+        }
+        return fmt.Errorf("failed to create bucket '%s': %w", bucketName, err)
     }
 
-    log.Info().Str("bucket", bucketName).Msg("🎉 Successfully created remote state bucket")
+    log.Info().
+        Str("bucket", bucketName).
+        Str("location", newAttrs.Location).
+        Bool("cmek", settings.KMSKey != "").
+        Msg("🎉 Successfully created hardened remote state bucket")
     return nil
 }
 
-func createDefaultFiles(log *zerolog.Logger, dir, projectID string) error {
+func createDefaultFiles(log *zerolog.Logger, dir, projectID, region, statePrefix string) error {
+    if region == "" {
+        region = "me-west1"
+    }
+    if statePrefix == "" {
+        statePrefix = "terraform/state"
+    }
+
     // יצירת התיקייה במידה ולא קיימת
     if err := os.MkdirAll(dir, 0755); err != nil {
         return fmt.Errorf("failed to create directory: %w", err)
@@ -104,8 +186,8 @@ func createDefaultFiles(log *zerolog.Logger, dir, projectID string) error {
 
     // 2. backend.tfvars - מכיל את הערכים הספציפיים לבאקט
     backendVarsContent := fmt.Sprintf(`bucket = "%s-tfstate"
-prefix = "terraform/state"
-`, projectID)
+prefix = "%s"
+`, projectID, statePrefix)
 
     // 3. provider.tf - משתמש במשתנים במקום בערכים קבועים
     providerContent := `provider "google" {
@@ -118,18 +200,18 @@ provider "google-beta" {
 }`
 
     // 4. variables.tf - הגדרת המשתנים
-    variablesContent := `variable "project_id" {
+    variablesContent := fmt.Sprintf(`variable "project_id" {
   type = string
 }
 variable "region" {
   type    = string
-  default = "me-west1"
-}`
+  default = "%s"
+}`, region)
 
     // 5. terraform.tfvars - הערכים למשתנים
     tfvarsContent := fmt.Sprintf(`project_id = "%s"
-region     = "me-west1"
-`, projectID)
+region     = "%s"
+`, projectID, region)
 
     // מפת קבצים לכתיבה נוחה בלולאה
     files := map[string]string{
@@ -152,30 +234,50 @@ region     = "me-west1"
     return nil
 }
 
+// Init מאתחל את התיקייה ובוחר את ה-workspace של config
 func Init(log *zerolog.Logger, config TFConfig) error {
+	if err := initWorkingDir(log, config); err != nil {
+		return err
+	}
+	return SelectWorkspace(log, config)
+}
+
+func initWorkingDir(log *zerolog.Logger, config TFConfig) error {
 	log.Info().Str("dir", config.Dir).Msg("🛠️ Initializing Terraform...")
 
-	baseArgs := []string{"init", "-upgrade", "-input=false"}
-	if config.BackendVarsFile != "" {
-		baseArgs = append(baseArgs, fmt.Sprintf("-backend-config=%s", config.BackendVarsFile))
+	env, cleanup, err := initEnv(log, config)
+	defer cleanup()
+	if err != nil {
+		return err
 	}
 
-	_, err := RunTerraform(log, config.Dir, baseArgs...)
+	baseArgs := initArgs(config)
+	out, err := RunTerraformWithEnv(log, config.Dir, env, baseArgs...)
 	if err == nil {
 		return nil
 	}
 
-	log.Warn().Msg("⚠️ Standard init failed, trying -reconfigure...")
-	reconfigArgs := append(baseArgs, "-reconfigure")
-	_, err = RunTerraform(log, config.Dir, reconfigArgs...)
-	if err == nil {
-		return nil
+	// מטפלים רק בכישלון שזוהה, ורק בתרופה שמתאימה לו - בלי הסלמה עיוורת ל--migrate-state
+	failure := classifyInitFailure(out)
+	log.Warn().Str("failure", string(failure)).Msg("⚠️ Terraform init failed")
+
+	switch failure {
+	case InitFailureBackendChanged:
+		return initBackendChanged(log, config, env, baseArgs)
+	case InitFailureLockMismatch:
+		return initLockMismatch(log, config, err)
+	case InitFailurePluginDownload:
+		if config.Providers.Offline {
+			log.Error().Msg("❌ Provider missing from the offline mirror - refresh it with -cmd providers-mirror")
+			return err
+		}
+		return initRetryDownload(log, config, env, baseArgs)
+	case InitFailureAuth:
+		log.Error().Msg("❌ Terraform could not authenticate to GCP - re-run the GCP login / check credentials, then retry")
+		return fmt.Errorf("%w: %v", ErrInitAuth, err)
+	default:
+		return err
 	}
-
-	log.Warn().Msg("⚠️ Reconfigure failed, trying -migrate-state...")
-	migrateArgs := append(baseArgs, "-migrate-state")
-	_, err = RunTerraform(log, config.Dir, migrateArgs...)
-	return err
 }
 
 func Apply(log *zerolog.Logger, config TFConfig) error {
@@ -187,35 +289,24 @@ func Apply(log *zerolog.Logger, config TFConfig) error {
         args = append(args, fmt.Sprintf("-var-file=%s", config.VarFile))
     }
     
-    // הוספת משתנים בודדים (כמו Project ID)
-    for key, value := range config.Vars {
-        args = append(args, "-var", fmt.Sprintf("%s=%s", key, value))
-    }
+    // משתנים בודדים (כמו Project ID) עוברים בקובץ זמני / TF_VAR_ - לא בשורת הפקודה
+    args = append(args, config.Targeting.args()...)
 
-    _, err := RunTerraform(log, config.Dir, args...)
+    _, err := runWithVars(log, config, args...)
     return err
 }
 
 func Destroy(log *zerolog.Logger, config TFConfig) error {
 	log.Info().Msg("🔥 Running Terraform Destroy...")
-	args := []string{"destroy", "-auto-approve"}
-	if config.VarFile != "" {
-		args = append(args, fmt.Sprintf("-var-file=%s", config.VarFile))
-	}
-	_, err := RunTerraform(log, config.Dir, args...)
+	args := append([]string{"destroy", "-auto-approve"}, planArgs(config)...)
+	args = append(args, config.Targeting.args()...)
+	_, err := runWithVars(log, config, args...)
 	return err
 }
 
 
 // deleteGCSBucket מוחק את כל האובייקטים בבוקט ואז מוחק את הבוקט עצמו
-func deleteGCSBucket(log *zerolog.Logger, projectID, bucketName string) error {
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to create storage client: %v", err)
-	}
-	defer client.Close()
-
+func deleteGCSBucket(ctx context.Context, log *zerolog.Logger, client *storage.Client, bucketName string) error {
 	bucket := client.Bucket(bucketName)
 
 	// GCP מחייב שהבוקט יהיה ריק לפני מחיקה. נמחק את כל האובייקטים (קובצי ה-state):
@@ -241,68 +332,238 @@ func deleteGCSBucket(log *zerolog.Logger, projectID, bucketName string) error {
 	return nil
 }
 
-// RunTerraformWorkflow - הפונקציה המרכזית המעודכנת
+// RunTerraformWorkflow - הפונקציה המרכזית המעודכנת. עוצרת את התהליך בכל כישלון
 func RunTerraformWorkflow(log *zerolog.Logger, opts TerraformOptions) {
-	log.Info().Msg("🚀 Starting Smart Terraform Workflow")
+	if _, err := ExecuteTerraformWorkflow(log, opts); err != nil {
+		log.Fatal().Err(err).Msg("❌ Terraform workflow failed")
+	}
+}
+
+// ExecuteTerraformWorkflow מריץ את אותו תהליך אבל מחזיר שגיאה במקום לעצור את התהליך
+// (נדרש כשמריצים כמה סביבות ברצף)
+// אחרי apply מוחזרים ה-outputs של Terraform לשלבים הבאים ב-pipeline.
+// כל הרצה נרשמת בהיסטוריה - הרצה עם -target / -refresh-only מסומנת כחלקית.
+// סביבת ה-GCP נבדקת לפני כן אצל הקורא (gcpUtils.CheckGCP)
+func ExecuteTerraformWorkflow(log *zerolog.Logger, opts TerraformOptions) (outputs Outputs, err error) {
+	entry := workflowEntry(opts)
+	defer func() {
+		entry.Finish(err)
+		if recErr := history.Record(entry); recErr != nil {
+			log.Warn().Err(recErr).Msg("⚠️ Failed to record Terraform run in history")
+		}
+	}()
+	return executeTerraformWorkflow(log, opts)
+}
+
+func executeTerraformWorkflow(log *zerolog.Logger, opts TerraformOptions) (Outputs, error) {
+	log.Info().Str("dir", opts.TerraformDir).Str("project", opts.ProjectID).Msg("🚀 Starting Smart Terraform Workflow")
+
+	if err := opts.Targeting.Validate(opts.Destroy); err != nil {
+		log.Error().Err(err).Msg("❌ Invalid targeting options")
+		return nil, err
+	}
+	opts.Targeting.logScope(log)
+
+	// 0. destroy - סביבה מוגנת נחסמת לגמרי, וכל השאר דורש הקלדת מזהה הפרויקט
+	if opts.Destroy {
+		if opts.Protected {
+			log.Error().Str("project", opts.ProjectID).Msg("🛑 Destroy refused - environment is protected")
+			return nil, ErrDestroyProtected
+		}
+		if opts.WorkspaceProtected() {
+			log.Error().Str("workspace", workspaceName(opts.Workspace)).Msg("🛑 Destroy refused - workspace is protected")
+			return nil, ErrWorkspaceProtected
+		}
+		if err := confirmDestroy(log, opts.ProjectID, opts.ConfirmProjectID, opts.NonInteractive); err != nil {
+			return nil, err
+		}
+	}
 
-	// 1. בדיקת GCP
-	gcpUtils.RunGCPCheck(log, opts.ProjectID)
+	// 1. בדיקת GCP - פעם אחת לכל סביבה אצל הקורא (gcpUtils.CheckGCP), לא שוב לכל stack
 
 	// 2. בדיקת קבצים - אם אין קבצי tf, ניצור ברירת מחדל
 	files, _ := filepath.Glob(filepath.Join(opts.TerraformDir, "*.tf"))
 	if len(files) == 0 {
-		if err := createDefaultFiles(log, opts.TerraformDir, opts.ProjectID); err != nil {
-			log.Fatal().Err(err).Msg("❌ Failed to create default files")
+		if err := createDefaultFiles(log, opts.TerraformDir, opts.ProjectID, opts.Region, opts.StatePrefix); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to create default files")
+			return nil, err
 		}
 	}
 
 	// 3. חילוץ שם הבוקט ווידוא קיומו ב-GCP (ה-Parser סורק את כל הקבצים)
+	ctx := context.Background()
+	storageClient, err := NewStorageClient(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Failed to create GCP storage client")
+		return nil, err
+	}
+	defer storageClient.Close()
+
 	bucketName := ExtractBackendBucket(log, opts.TerraformDir)
 	if bucketName != "" {
-		if err := ensureGCSBucket(log, opts.ProjectID, bucketName); err != nil {
-			log.Fatal().Err(err).Msg("❌ Failed to verify or create the remote state bucket. Stopping workflow.")
+		bucketSettings := opts.StateBucket
+		if bucketSettings.Location == "" {
+			bucketSettings.Location = opts.Region
+		}
+		if bucketSettings.Location == "" {
+			bucketSettings.Location = "me-west1" // כמו ב-createDefaultFiles
+		}
+		if err := ensureGCSBucket(ctx, log, storageClient, opts.ProjectID, bucketName, bucketSettings); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to verify or create the remote state bucket. Stopping workflow.")
+			return nil, err
 		}
 	} else {
-		log.Fatal().Msg("❌ Critical Error: No GCS bucket name could be extracted from .tf files or backend config. Terraform cannot manage state.")
+		log.Error().Msg("❌ Critical Error: No GCS bucket name could be extracted from .tf files or backend config. Terraform cannot manage state.")
+		return nil, errors.New("no GCS backend bucket configured")
 	}
 
-	tfConfig := TFConfig{
-		Dir:             opts.TerraformDir,
-		VarFile:         opts.VarFile,
-		BackendVarsFile: opts.BackendVarsFile,
-		// Vars: map[string]string{
-		// 	"project_info": opts.ProjectID,
-			
-		// },
-	}
+	tfConfig := opts.tfConfig()
 
 	// 4. אתחול
 	if err := Init(log, tfConfig); err != nil {
-		log.Fatal().Err(err).Msg("❌ Terraform Init failed")
+		log.Error().Err(err).Msg("❌ Terraform Init failed")
+		return nil, err
+	}
+
+	workspaces, err := ListWorkspaces(log, opts.TerraformDir)
+	if err != nil {
+		log.Warn().Err(err).Msg("⚠️ Failed to list Terraform workspaces")
+	} else {
+		rememberWorkspaces(opts, workspaces)
+	}
+
+	// destroy - בודקים את ה-workspace שנבחר בפועל, לא רק את מה שהתבקש
+	if opts.Destroy {
+		selected, err := currentWorkspace(log, opts.TerraformDir)
+		if err != nil {
+			return nil, err
+		}
+		if requested := workspaceName(opts.Workspace); selected != requested {
+			log.Error().Str("workspace", selected).Str("requested", requested).Msg("🛑 Destroy refused - selected workspace is not the requested one")
+			return nil, fmt.Errorf("%w: selected %s, requested %s", ErrWorkspaceMismatch, selected, requested)
+		}
+		if slices.Contains(opts.ProtectedWorkspaces, selected) {
+			log.Error().Str("workspace", selected).Msg("🛑 Destroy refused - selected workspace is protected")
+			return nil, fmt.Errorf("%w: %s", ErrWorkspaceProtected, selected)
+		}
+	}
+
+	// 4.5 snapshot של ה-state לפני כל apply / destroy
+	if !opts.Snapshots.Disabled {
+		reason := "apply"
+		if opts.Destroy {
+			reason = "destroy"
+		}
+		stack := &stateStack{
+			config:    tfConfig,
+			bucket:    bucketName,
+			prefix:    ExtractBackendPrefix(log, opts.TerraformDir, opts.BackendVarsFile),
+			client:    storageClient,
+			settings:  opts.Snapshots,
+			workspace: opts.Workspace,
+		}
+		if _, err := snapshotState(ctx, log, stack, reason); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to snapshot Terraform state - stopping before changes")
+			return nil, err
+		}
 	}
 
 	// 5. הרצה
 	if opts.Destroy {
+		// גיבוי מקומי של ה-state לפני שנוגעים במשהו
+		prefix := ExtractBackendPrefix(log, opts.TerraformDir, opts.BackendVarsFile)
+		backupDir := opts.StateBackupDir
+		if backupDir == "" {
+			backupDir = DefaultStateBackupDir
+		}
+		if _, err := backupStatePrefix(ctx, log, storageClient, bucketName, prefix, backupDir); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to back up state - refusing to destroy")
+			return nil, err
+		}
+
 		// הרצת ה-Destroy של המשאבים בתוך טראפורם
 		if err := Destroy(log, tfConfig); err != nil {
-			log.Fatal().Err(err).Msg("❌ Terraform Destroy failed")
+			log.Error().Err(err).Msg("❌ Terraform Destroy failed")
+			return nil, err
+		}
+
+		// destroy חלקי (-target) - שאר המשאבים עדיין ב-state, לא מוחקים אותו
+		if opts.Targeting.Partial() {
+			log.Info().Msg("✨ Targeted destroy completed - state kept for the remaining resources")
+			return nil, nil
 		}
 
-		// אם ה-Destroy הצליח, נמחק גם את הבוקט של ה-State
-		if bucketName != "" {
-			log.Info().Str("bucket", bucketName).Msg("🗑️ Terraform Destroy succeeded. Deleting state bucket...")
-			if err := deleteGCSBucket(log, opts.ProjectID, bucketName); err != nil {
-				log.Error().Err(err).Msg("❌ Failed to delete state bucket")
-			} else {
-				log.Info().Msg("✅ State bucket deleted successfully")
-			}
+		// אם ה-Destroy הצליח, נמחק רק את ה-state של ה-workspace שנהרס, בתוך ה-prefix של ה-stack -
+		// לא את הבוקט המשותף ולא workspaces אחרים (גם כשרשימת ה-workspaces לא זמינה)
+		workspace := workspaceName(opts.Workspace)
+		log.Info().Str("bucket", bucketName).Str("prefix", prefix).Str("workspace", workspace).Msg("🗑️ Terraform Destroy succeeded. Deleting this workspace's state...")
+		if deleted, err := deleteWorkspaceState(ctx, log, storageClient, bucketName, prefix, workspace); err != nil {
+			log.Error().Err(err).Msg("❌ Failed to delete stack state")
+		} else {
+			log.Info().Int("objects", deleted).Msg("✅ Stack state deleted successfully (bucket kept)")
+		}
+	} else if opts.EstimateCost {
+		// plan -> אומדן עלות -> apply של אותו plan בדיוק
+		if err := planEstimateApply(log, tfConfig, opts.PricingFile); err != nil {
+			return nil, err
 		}
 	} else {
 		// הרצת Apply רגיל
 		if err := Apply(log, tfConfig); err != nil {
-			log.Fatal().Err(err).Msg("❌ Terraform Apply failed")
+			log.Error().Err(err).Msg("❌ Terraform Apply failed")
+			return nil, err
 		}
 	}
 
 	log.Info().Msg("✨ Terraform workflow completed successfully!")
-}
\ No newline at end of file
+	if opts.Destroy {
+		return nil, nil
+	}
+
+	// 6. outputs - ל-log viewer (ערכים רגישים מוסתרים), ל-web API ולשלבים הבאים
+	outputs, err := ReadOutputs(log, opts.TerraformDir)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Failed to read Terraform outputs")
+		return nil, err
+	}
+	outputs.Log(log, opts.TerraformDir)
+	rememberOutputs(opts.ProjectID, opts.TerraformDir, outputs)
+	return outputs, nil
+}
+
+// planEstimateApply שומר plan, מציג את סיכום השינויים ואת אומדן העלות, ומחיל את ה-plan השמור
+func planEstimateApply(log *zerolog.Logger, tfConfig TFConfig, pricingFile string) error {
+	if err := Plan(log, tfConfig, DefaultPlanFile); err != nil {
+		log.Error().Err(err).Msg("❌ Terraform Plan failed")
+		return err
+	}
+	defer os.Remove(filepath.Join(tfConfig.Dir, DefaultPlanFile))
+
+	plan, err := ShowPlan(log, tfConfig.Dir, DefaultPlanFile)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Failed to read Terraform plan")
+		return err
+	}
+	summary := Summarize(plan)
+
+	// אומדן עלות לא אמור לעצור פריסה - במקרה של כישלון ממשיכים בלעדיו
+	if pricing, err := LoadPricing(pricingFile); err != nil {
+		log.Warn().Err(err).Msg("⚠️ Cost estimate skipped")
+	} else {
+		summary.Cost = EstimateCost(plan, pricing)
+		summary.Cost.Log(log)
+	}
+	summary.Log(log)
+
+	// ב-refresh-only אין resource_changes - ה-plan מעדכן רק את ה-state, ותמיד מחילים אותו
+	if !summary.HasChanges() && !tfConfig.Targeting.RefreshOnly {
+		log.Info().Msg("✅ No changes. Infrastructure is up-to-date")
+		return nil
+	}
+
+	if err := ApplyPlan(log, tfConfig, DefaultPlanFile); err != nil {
+		log.Error().Err(err).Msg("❌ Terraform Apply failed")
+		return err
+	}
+	return nil
+}
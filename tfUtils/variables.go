@@ -0,0 +1,194 @@
+package tfUtils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"DevOps/gcpUtils"
+	"DevOps/logger"
+
+	"github.com/rs/zerolog"
+)
+
+// הפניות במקום ערך ב-Vars - הערך נקרא רק בזמן ההרצה ומוסתר בלוגים
+const (
+	VarRefEnv    = "env:"    // env:NAME - משתנה סביבה
+	VarRefFile   = "file:"   // file:PATH - תוכן קובץ (בלי ירידת השורה בסוף)
+	VarRefSecret = "secret:" // secret:NAME[@VERSION] או secret:projects/.../secrets/... - Secret Manager
+)
+
+// ערכי secret: שכבר נקראו (כדי לא לפנות ל-Secret Manager בכל פקודת terraform).
+// env: ו-file: נקראים מחדש בכל פעם - בבדיקת drift חוזרת ערך שהשתנה נקלט
+var (
+	resolvedMu   sync.Mutex
+	resolvedRefs = map[string]string{}
+)
+
+// resolveVar מחזיר את הערך האמיתי של משתנה. secret=true כשהערך הגיע מהפניה
+func resolveVar(ctx context.Context, value string) (resolved string, secret bool, err error) {
+	var read func() (string, error)
+	switch {
+	case strings.HasPrefix(value, VarRefEnv):
+		name := strings.TrimPrefix(value, VarRefEnv)
+		read = func() (string, error) {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			return v, nil
+		}
+	case strings.HasPrefix(value, VarRefFile):
+		path := strings.TrimPrefix(value, VarRefFile)
+		read = func() (string, error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}
+	case strings.HasPrefix(value, VarRefSecret):
+		name := strings.TrimPrefix(value, VarRefSecret)
+		read = func() (string, error) { return gcpUtils.AccessSecret(ctx, name) }
+	default:
+		return value, false, nil
+	}
+
+	if !strings.HasPrefix(value, VarRefSecret) {
+		v, err := read()
+		if err != nil {
+			return "", true, err
+		}
+		return v, true, nil
+	}
+
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+	if v, ok := resolvedRefs[value]; ok {
+		return v, true, nil
+	}
+	v, err := read()
+	if err != nil {
+		return "", true, err
+	}
+	resolvedRefs[value] = v
+	return v, true, nil
+}
+
+// resolveVars קורא את כל ההפניות. ערך סודי נרשם ב-logger כדי שיוסתר בכל שורת לוג
+func resolveVars(ctx context.Context, log *zerolog.Logger, vars map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(vars))
+	for key, value := range vars {
+		v, secret, err := resolveVar(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", key, err)
+		}
+		if secret {
+			logger.RegisterSecret(v)
+			log.Debug().Str("variable", key).Msg("🔐 Resolved secret Terraform variable")
+		}
+		resolved[key] = v
+	}
+	return resolved, nil
+}
+
+// templateEscaper - ${ ו-%{ מוכפלים כדי ש-Terraform לא יפרש אותם כ-template. ב-JSON הם
+// יכולים להופיע רק בתוך מחרוזות (ערכים או מפתחות), לכן בטוח להחליף על כל הטקסט
+var templateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// hclValue - ערך מחרוזת בקובץ tfvars: תמיד במירכאות, גם כשהוא נראה כמו JSON (ה-type לא
+// מנוחש - ערכים מורכבים מגיעים ב-Inputs או ב-VarFile)
+func hclValue(value string) string {
+	quoted, _ := json.Marshal(value)
+	return templateEscaper.Replace(string(quoted))
+}
+
+// inputValue - ערך של output בקובץ tfvars לפי ה-type שלו: מחרוזת במירכאות, כל השאר
+// (מספר, bool, רשימה, אובייקט) בקידוד JSON - שהוא גם HCL תקין. גם מחרוזות בתוך רשימה
+// או אובייקט עוברות escape
+func inputValue(o Output) string {
+	data, _ := json.Marshal(o.Value)
+	return templateEscaper.Replace(string(data))
+}
+
+// writeVarsFile כותב את המשתנים לקובץ tfvars זמני שרק המשתמש הנוכחי יכול לקרוא (0600).
+// inputs גוברים על vars באותו שם (כמו סדר ה-stacks)
+func writeVarsFile(vars map[string]string, inputs Outputs) (string, error) {
+	f, err := os.CreateTemp("", "terraform-vars-*.tfvars")
+	if err != nil {
+		return "", fmt.Errorf("failed to create vars file: %w", err)
+	}
+	defer f.Close()
+	if err := f.Chmod(0600); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	lines := make(map[string]string, len(vars)+len(inputs))
+	for key, value := range vars {
+		lines[key] = hclValue(value)
+	}
+	for key, out := range inputs {
+		lines[key] = inputValue(out)
+	}
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, lines[key])
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write vars file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// prepareVars מעביר את Vars ו-Inputs ל-terraform בלי שהערכים יופיעו בשורת הפקודה (ps / לוגים):
+// בקובץ tfvars זמני (ברירת מחדל - גובר על VarFile כמו -var), או Vars ב-TF_VAR_ כש-VarsViaEnv.
+// Inputs (outputs של stacks קודמים, חלקם רגישים) תמיד בקובץ. cleanup מוחק את הקובץ הזמני
+func prepareVars(log *zerolog.Logger, config TFConfig) (env, args []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if len(config.Vars) == 0 && len(config.Inputs) == 0 {
+		return nil, nil, cleanup, nil
+	}
+
+	vars, err := resolveVars(context.Background(), log, config.Vars)
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+
+	if config.VarsViaEnv {
+		for key, value := range vars {
+			env = append(env, "TF_VAR_"+key+"="+value)
+		}
+		vars = nil
+	}
+	if len(vars) == 0 && len(config.Inputs) == 0 {
+		return env, nil, cleanup, nil
+	}
+
+	path, err := writeVarsFile(vars, config.Inputs)
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+	return env, []string{"-var-file=" + path}, func() { os.Remove(path) }, nil
+}
+
+// runWithVars מריץ פקודת terraform שצריכה את המשתנים (plan / apply / destroy)
+func runWithVars(log *zerolog.Logger, config TFConfig, args ...string) (string, error) {
+	env, varArgs, cleanup, err := prepareVars(log, config)
+	defer cleanup()
+	if err != nil {
+		return "", err
+	}
+	return RunTerraformWithEnv(log, config.Dir, env, append(args, varArgs...)...)
+}
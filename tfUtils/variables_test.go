@@ -0,0 +1,113 @@
+package tfUtils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHCLValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"dev", `"dev"`},
+		{"42", `"42"`},
+		{`["a","b"]`, `"[\"a\",\"b\"]"`},
+		{`{"key":"value"}`, `"{\"key\":\"value\"}"`},
+		{"${var.name}", `"$${var.name}"`},
+		{"%{ if true }", `"%%{ if true }"`},
+		{"line1\nline2", `"line1\nline2"`},
+	}
+	for _, tt := range tests {
+		if got := hclValue(tt.value); got != tt.want {
+			t.Errorf("hclValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInputValue(t *testing.T) {
+	tests := []struct {
+		name string
+		out  Output
+		want string
+	}{
+		{"string", Output{Type: "string", Value: `["x"]`}, `"[\"x\"]"`},
+		{"number", Output{Type: "number", Value: float64(3)}, `3`},
+		{"bool", Output{Type: "bool", Value: true}, `true`},
+		{"list", Output{Type: "list", Value: []any{"a", "b"}}, `["a","b"]`},
+		{"map", Output{Type: "map", Value: map[string]any{"k": "v"}}, `{"k":"v"}`},
+		{"string template", Output{Type: "string", Value: "${var.x}"}, `"$${var.x}"`},
+		{"list template", Output{Type: "list", Value: []any{"${a}", "%{ if b }", "c"}}, `["$${a}","%%{ if b }","c"]`},
+		{"map template", Output{Type: "map", Value: map[string]any{"${k}": "v-${x}", "n": []any{"%{y}"}}}, `{"$${k}":"v-$${x}","n":["%%{y}"]}`},
+	}
+	for _, tt := range tests {
+		if got := inputValue(tt.out); got != tt.want {
+			t.Errorf("%s: inputValue() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteVarsFileInputsWin(t *testing.T) {
+	vars := map[string]string{"region": "europe-west1", "subnets": `["10.0.0.0/24"]`}
+	inputs := Outputs{"region": {Name: "region", Type: "string", Value: "us-central1"}}
+
+	path, err := writeVarsFile(vars, inputs)
+	if err != nil {
+		t.Fatalf("writeVarsFile: %v", err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("vars file mode = %o, want 600", perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "region = \"us-central1\"\nsubnets = \"[\\\"10.0.0.0/24\\\"]\"\n"
+	if got := string(data); got != want {
+		t.Errorf("vars file =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(string(data), "europe-west1") {
+		t.Errorf("var overridden by an input still written: %s", data)
+	}
+}
+
+func TestResolveVarRereadsEnvAndFile(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TF_TEST_RESOLVE", "first")
+	path := filepath.Join(t.TempDir(), "value")
+	if err := os.WriteFile(path, []byte("one\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct{ env, file string }{{"first", "one"}, {"second", "two"}} {
+		t.Setenv("TF_TEST_RESOLVE", step.env)
+		if err := os.WriteFile(path, []byte(step.file+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		got, secret, err := resolveVar(ctx, VarRefEnv+"TF_TEST_RESOLVE")
+		if err != nil || !secret || got != step.env {
+			t.Errorf("resolveVar(env) = %q, %v, %v, want %q", got, secret, err, step.env)
+		}
+		got, secret, err = resolveVar(ctx, VarRefFile+path)
+		if err != nil || !secret || got != step.file {
+			t.Errorf("resolveVar(file) = %q, %v, %v, want %q", got, secret, err, step.file)
+		}
+	}
+
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+	if len(resolvedRefs) != 0 {
+		t.Errorf("env/file references cached: %v", resolvedRefs)
+	}
+}
@@ -0,0 +1,186 @@
+package tfUtils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/rs/zerolog"
+)
+
+// DefaultWorkspace - ה-workspace ש-Terraform יוצר לבד
+const DefaultWorkspace = "default"
+
+// ErrWorkspaceProtected - destroy נחסם כי ה-workspace מסומן כמוגן
+var ErrWorkspaceProtected = errors.New("destroy refused: workspace is protected")
+
+// ErrWorkspaceMismatch - destroy נחסם כי ה-workspace שנבחר בפועל אינו זה שהתבקש
+var ErrWorkspaceMismatch = errors.New("destroy refused: selected workspace is not the requested one")
+
+// Workspace is one Terraform workspace of a stack.
+type Workspace struct {
+	Name      string `json:"name"`
+	Current   bool   `json:"current"`
+	Protected bool   `json:"protected"`
+}
+
+// workspaceName - ה-workspace בפועל (ריק = default)
+func workspaceName(name string) string {
+	if name == "" {
+		return DefaultWorkspace
+	}
+	return name
+}
+
+// WorkspaceProtected - האם ה-workspace של האופציות ברשימת ה-workspaces המוגנים
+func (opts TerraformOptions) WorkspaceProtected() bool {
+	return slices.Contains(opts.ProtectedWorkspaces, workspaceName(opts.Workspace))
+}
+
+// SelectWorkspace בוחר את ה-workspace של config (ויוצר אותו אם אינו קיים). תמיד בוחרים במפורש -
+// ריק = default, כדי שה-workspace שנשאר ב-.terraform/environment מהרצה קודמת לא ישמש בטעות
+// (ולא יעקוף את בדיקת ה-workspaces המוגנים)
+func SelectWorkspace(log *zerolog.Logger, config TFConfig) error {
+	name := workspaceName(config.Workspace)
+
+	workspaces, err := ListWorkspaces(log, config.Dir)
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		if ws.Name != name {
+			continue
+		}
+		if ws.Current {
+			return nil
+		}
+		log.Info().Str("workspace", name).Msg("🗂️ Selecting Terraform workspace")
+		if _, err := RunTerraform(log, config.Dir, "workspace", "select", name); err != nil {
+			return fmt.Errorf("failed to select workspace %s: %w", name, err)
+		}
+		return nil
+	}
+
+	log.Info().Str("workspace", name).Msg("🆕 Creating Terraform workspace")
+	if _, err := RunTerraform(log, config.Dir, "workspace", "new", name); err != nil {
+		return fmt.Errorf("failed to create workspace %s: %w", name, err)
+	}
+	return nil
+}
+
+// currentWorkspace - ה-workspace שנבחר בפועל בתיקייה (terraform workspace show)
+func currentWorkspace(log *zerolog.Logger, dir string) (string, error) {
+	out, err := RunTerraformOutput(log, dir, "workspace", "show")
+	if err != nil {
+		return "", fmt.Errorf("terraform workspace show failed: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ListWorkspaces returns the workspaces of the (initialized) stack in dir.
+func ListWorkspaces(log *zerolog.Logger, dir string) ([]Workspace, error) {
+	out, err := RunTerraformOutput(log, dir, "workspace", "list")
+	if err != nil {
+		return nil, fmt.Errorf("terraform workspace list failed: %w", err)
+	}
+	return parseWorkspaces(out), nil
+}
+
+// parseWorkspaces מפרק את הפלט של workspace list - ה-workspace הנוכחי מסומן ב-"*"
+func parseWorkspaces(out string) []Workspace {
+	var workspaces []Workspace
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		current := strings.HasPrefix(line, "*")
+		name := strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		if name == "" {
+			continue
+		}
+		workspaces = append(workspaces, Workspace{Name: name, Current: current})
+	}
+	return workspaces
+}
+
+// stateObjectInWorkspace - ה-backend של GCS שומר כל workspace כ-<prefix>/<workspace>.tfstate (+ .tflock)
+func stateObjectInWorkspace(name, workspace string) bool {
+	base := path.Base(name)
+	return base == workspace+".tfstate" || base == workspace+".tflock"
+}
+
+// deleteWorkspaceState מוחק רק את ה-state של workspace אחד ב-prefix - שאר ה-workspaces נשארים
+func deleteWorkspaceState(ctx context.Context, log *zerolog.Logger, client *storage.Client, bucketName, prefix, workspace string) (int, error) {
+	if strings.Trim(prefix, "/") == "" {
+		return 0, ErrRootStatePrefix
+	}
+	names, err := listStateObjects(ctx, client, bucketName, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, name := range names {
+		if !stateObjectInWorkspace(name, workspace) {
+			continue
+		}
+		if err := client.Bucket(bucketName).Object(name).Delete(ctx); err != nil {
+			return deleted, fmt.Errorf("failed to delete object %s: %v", name, err)
+		}
+		log.Debug().Str("object", name).Msg("Deleted workspace state object")
+		deleted++
+	}
+	return deleted, nil
+}
+
+// StackWorkspaces - ה-workspaces של stack, כפי שמוצגים ב-web API
+type StackWorkspaces struct {
+	ProjectID  string      `json:"project"`
+	Dir        string      `json:"dir"`
+	Updated    time.Time   `json:"updated"`
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+var (
+	workspacesMu     sync.Mutex
+	latestWorkspaces = map[string]StackWorkspaces{}
+)
+
+// rememberWorkspaces שומר את רשימת ה-workspaces של ה-stack עבור ה-API
+func rememberWorkspaces(opts TerraformOptions, workspaces []Workspace) {
+	for i := range workspaces {
+		workspaces[i].Protected = slices.Contains(opts.ProtectedWorkspaces, workspaces[i].Name)
+	}
+
+	workspacesMu.Lock()
+	defer workspacesMu.Unlock()
+	latestWorkspaces[opts.ProjectID+"|"+opts.TerraformDir] = StackWorkspaces{
+		ProjectID:  opts.ProjectID,
+		Dir:        opts.TerraformDir,
+		Updated:    time.Now(),
+		Workspaces: workspaces,
+	}
+}
+
+// LatestWorkspaces מחזיר את ה-workspaces של כל stack שהורץ בתהליך הזה
+func LatestWorkspaces() []StackWorkspaces {
+	workspacesMu.Lock()
+	defer workspacesMu.Unlock()
+
+	result := make([]StackWorkspaces, 0, len(latestWorkspaces))
+	for _, s := range latestWorkspaces {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ProjectID != result[j].ProjectID {
+			return result[i].ProjectID < result[j].ProjectID
+		}
+		return result[i].Dir < result[j].Dir
+	})
+	return result
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"crypto/subtle"
 	"embed"
+	"encoding/json"
+	"mime"
 	"net/http"
+	"strings"
 	"DevOps/logger" // וודא שהנתיב ל-logger נכון
+	"DevOps/tfUtils"
 	"github.com/gorilla/websocket"
 	"time"
 )
@@ -40,6 +45,109 @@ func handleWebSockets(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeJSON מחזיר תשובת JSON ל-API של ה-dashboard
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to encode API response")
+	}
+}
+
+// handleDrift מחזיר את בדיקת ה-drift האחרונה של כל stack
+func handleDrift(w http.ResponseWriter, r *http.Request) {
+	reports, err := tfUtils.LatestDriftReports()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// handleOutputs מחזיר את ה-outputs האחרונים של כל stack (ערכים רגישים מוסתרים)
+func handleOutputs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, tfUtils.LatestOutputs())
+}
+
+// handleWorkspaces מחזיר את ה-workspaces של כל stack (הנוכחי והמוגנים מסומנים)
+func handleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, tfUtils.LatestWorkspaces())
+}
+
+// terraformRunRequest - גוף הבקשה ל-POST /api/terraform/run
+type terraformRunRequest struct {
+	Env string `json:"env"` // סביבה אחת או כמה מופרדות בפסיקים
+	tfUtils.Targeting
+}
+
+// authorizeAPI - בקשה שמשנה תשתית חייבת Bearer token (-api-token). בלי token מוגדר ה-API כבוי
+func authorizeAPI(r *http.Request) (int, string) {
+	if *apiToken == "" {
+		return http.StatusForbidden, "Terraform API is disabled - start with -api-token (or DEVOPS_API_TOKEN)"
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(*apiToken)) != 1 {
+		return http.StatusUnauthorized, "missing or invalid bearer token"
+	}
+	return http.StatusOK, ""
+}
+
+// handleTerraformRun מפעיל apply (אפשר עם target / replace / refresh-only) ברקע.
+// destroy לא זמין מה-API - הוא דורש אישור בטרמינל
+func handleTerraformRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	if status, msg := authorizeAPI(r); status != http.StatusOK {
+		log.Warn().Str("remote_addr", r.RemoteAddr).Int("status", status).Msg("🚫 Rejected Terraform API request")
+		writeJSON(w, status, map[string]string{"error": msg})
+		return
+	}
+	// רק application/json - טופס או text/plain מדף אחר (CSRF) לא מגיעים לכאן
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req terraformRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	envs, err := selectEnvironments(req.Env)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := req.Targeting.Validate(false); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// סביבות מוגנות רק מה-CLI, אלא אם הותר במפורש
+	if !*apiAllowProtected {
+		for _, env := range envs {
+			if isProtected(env.Name) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "environment " + env.Name + " is protected - API runs are not allowed (see -api-allow-protected)"})
+				return
+			}
+		}
+	}
+
+	if !workflowMu.TryLock() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "a Terraform run is already in progress"})
+		return
+	}
+	log.Info().Str("env", req.Env).Str("remote_addr", r.RemoteAddr).Bool("partial", req.Targeting.Partial()).Msg("🌐 Terraform run requested from the API")
+	go func() {
+		defer workflowMu.Unlock()
+		// אין טרמינל מאחורי בקשת API - שאלה ב-stdin הייתה תוקעת את workflowMu
+		runWorkflow(envs, false, req.Targeting, false)
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"status": "accepted", "env": req.Env, "partial": req.Targeting.Partial()})
+}
+
 // startWebServer מגדיר ומפעיל את שרת האינטרנט
 func startWebServer() {
 	// הגשת קובץ ה-HTML הראשי (המציג את הלוגים)
@@ -47,12 +155,18 @@ func startWebServer() {
 	
 	// נקודת הקצה (Endpoint) לחיבורי WebSocket
 	http.HandleFunc("/ws/logs", handleWebSockets)
+
+	// API ל-dashboard
+	http.HandleFunc("/api/drift", handleDrift)
+	http.HandleFunc("/api/outputs", handleOutputs)
+	http.HandleFunc("/api/workspaces", handleWorkspaces)
+	http.HandleFunc("/api/terraform/run", handleTerraformRun)
 	
-	port := "9090"
-	log.Info().Msgf("🌐 Starting Web Server on http://localhost:%s. Open this URL in your browser to view logs.", port)
+	// ברירת מחדל: 127.0.0.1 בלבד - ה-API מפעיל apply
+	log.Info().Msgf("🌐 Starting Web Server on http://%s. Open this URL in your browser to view logs.", *listenAddr)
 
 	// הפעלת השרת
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	if err := http.ListenAndServe(*listenAddr, nil); err != nil {
 		log.Fatal().Err(err).Msg("Web server failed to start")
 	}
 	// 3. המתן כמה שניות לוודא שהשרת עלה